@@ -0,0 +1,82 @@
+package gpxgo
+
+const (
+	AX_NAMESPACE  = "http://www.garmin.com/xmlschemas/ActivityExtension/v2"
+	AX_PREFIX     = "ax"
+	TCX_NAMESPACE = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+)
+
+// The namespace of the extensions holding data without a GPX or Garmin
+// extension equivalent, e.g. DistanceMeters
+const (
+	GPXGO_NAMESPACE = "https://github.com/pikeszfish/gpxgo/v1"
+	GPXGO_PREFIX    = "gpxgo"
+)
+
+// ActivityExtension is the Garmin ActivityExtension v2 TPX of a track point,
+// as found in TCX files. Heart rate and cadence are in the
+// TrackPointExtension.
+type ActivityExtension struct {
+	Speed      NullableFloat64 `xml:"Speed,omitempty"`      // m/s
+	RunCadence NullableInt     `xml:"RunCadence,omitempty"` // steps per minute
+	Watts      NullableInt     `xml:"Watts,omitempty"`
+}
+
+func init() {
+	RegisterExtension(AX_NAMESPACE, "TPX", AX_PREFIX, ActivityExtension{})
+	// The distance of a TCX or FIT track point, in meters from the start
+	RegisterExtension(GPXGO_NAMESPACE, "DistanceMeters", GPXGO_PREFIX, NullableFloat64{})
+}
+
+/*==========================================================*/
+// ActivityExtension
+func (ax *ActivityExtension) interpolate(v, v2 interface{}, fraction float64) {
+	ax1, ok := v.(*ActivityExtension)
+	ax2, ok2 := v2.(*ActivityExtension)
+	if !ok || !ok2 {
+		return
+	}
+	interpolateFloat(&ax.Speed, ax1.Speed, ax2.Speed, fraction)
+	interpolateInt(&ax.RunCadence, ax1.RunCadence, ax2.RunCadence, fraction)
+	interpolateInt(&ax.Watts, ax1.Watts, ax2.Watts, fraction)
+}
+
+/*==========================================================*/
+// Wpt
+
+// ActivityExtension returns the ActivityExtension of the point, or nil if it
+// has none.
+func (wp *Wpt) ActivityExtension() (*ActivityExtension, error) {
+	v, err := wp.Extensions.Lookup(AX_NAMESPACE, "TPX")
+	ext, _ := v.(*ActivityExtension)
+	return ext, err
+}
+
+// SetActivityExtension replaces the ActivityExtension of the point, or
+// removes it if ext is nil.
+func (wp *Wpt) SetActivityExtension(ext *ActivityExtension) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	wp.Extensions = wp.Extensions.Set(AX_NAMESPACE, "TPX", v)
+}
+
+// DistanceMeters returns the distance from the start of the activity stored
+// with the point, as read from TCX, null if unknown.
+func (wp *Wpt) DistanceMeters() NullableFloat64 {
+	if distance, ok := wp.Extensions.Get(GPXGO_NAMESPACE, "DistanceMeters").(*NullableFloat64); ok {
+		return *distance
+	}
+	return NullableFloat64{}
+}
+
+// SetDistanceMeters replaces the distance stored with the point, or removes
+// it if distance is null.
+func (wp *Wpt) SetDistanceMeters(distance NullableFloat64) {
+	var v interface{}
+	if distance.Valid {
+		v = &distance
+	}
+	wp.Extensions = wp.Extensions.Set(GPXGO_NAMESPACE, "DistanceMeters", v)
+}
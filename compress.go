@@ -0,0 +1,185 @@
+package gpxgo
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrArchive is returned by ParseWithReader for a zip archive, which may hold
+// several documents, see ParseAllWithReader.
+var ErrArchive = errors.New("gpxgo: zip archive, use ParseAll")
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+	// an archive without files
+	emptyZipMagic = []byte("PK\x05\x06")
+)
+
+/*==========================================================*/
+// Static
+
+// ParseAllWithContent parses every GPX document of content: the documents of
+// a zip archive, in archive order, or else content itself.
+func ParseAllWithContent(content []byte) ([]*Gpx, error) {
+	return parseAll(bytes.NewReader(content), int64(len(content)))
+}
+
+// ParseAllWithReader parses every GPX document read from r, see
+// ParseAllWithContent. Zip archives are read in memory, other documents are
+// parsed as they are read.
+func ParseAllWithReader(r io.Reader) ([]*Gpx, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !isZip(magic) {
+		gpx, err := ParseWithReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return []*Gpx{gpx}, nil
+	}
+
+	content, err := ioutil.ReadAll(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAllWithContent(content)
+}
+
+// ParseAllWithPath parses every GPX document of the file at path, see
+// ParseAllWithContent.
+func ParseAllWithPath(path string) ([]*Gpx, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return parseAll(file, info.Size())
+}
+
+func parseAll(r io.ReaderAt, size int64) ([]*Gpx, error) {
+	magic := make([]byte, len(zipMagic))
+	n, _ := r.ReadAt(magic, 0)
+	if !isZip(magic[:n]) {
+		gpx, err := ParseWithReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		return []*Gpx{gpx}, nil
+	}
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Gpx
+	for _, file := range archive.File {
+		if !isGpxFile(file.Name) {
+			continue
+		}
+		gpx, err := parseZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		result = append(result, gpx)
+	}
+	return result, nil
+}
+
+func parseZipFile(file *zip.File) (*Gpx, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseWithReader(r)
+}
+
+func isZip(magic []byte) bool {
+	return bytes.HasPrefix(magic, zipMagic) || bytes.HasPrefix(magic, emptyZipMagic)
+}
+
+// isGpxFile returns whether the archived file name is of a GPX document,
+// possibly compressed. Directories and macOS resource forks are not.
+func isGpxFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasSuffix(name, "/") || strings.HasPrefix(base, "._") {
+		return false
+	}
+	base = strings.ToLower(base)
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".gz"), ".bz2")
+	return strings.HasSuffix(base, ".gpx")
+}
+
+// decompress returns r, decompressed if it starts with the magic bytes of
+// gzip or bzip2.
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(buffered), nil
+	case isZip(magic):
+		return nil, ErrArchive
+	}
+	return buffered, nil
+}
+
+/*==========================================================*/
+// decompressor
+
+// decompressor reads r decompressed, as decompress, from the first Read on,
+// so that nothing is read before it is needed.
+type decompressor struct {
+	r       io.Reader
+	err     error
+	started bool
+}
+
+func (z *decompressor) Read(p []byte) (int, error) {
+	if !z.started {
+		z.started = true
+		z.r, z.err = decompress(z.r)
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	return z.r.Read(p)
+}
+
+/*==========================================================*/
+// Gpx
+
+// WriteGzip writes the document to w like WriteXML, gzip compressed.
+func (g *Gpx) WriteGzip(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := g.WriteXML(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,129 @@
+package gpxgo
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/bmizerany/assert"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	w.Write(content)
+	assert.Equal(t, nil, w.Close())
+	return buffer.Bytes()
+}
+
+func TestParseCompressed(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+	gpx, err := ParseWithContent(gzipped(t, content))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(gpx.Tracks))
+
+	bz2, err := ParseWithPath("testdata/gpx10_sample.gpx.bz2")
+	assert.Equal(t, nil, err)
+	plain, err := ParseWithPath("testdata/gpx10_sample.gpx")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, plain.ToXML(), bz2.ToXML())
+
+	all, err := ParseAllWithReader(bytes.NewReader(gzipped(t, content)))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(all))
+	assert.Equal(t, gpx.ToXML(), all[0].ToXML())
+}
+
+func TestDecoderCompressed(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	dec := NewDecoder(bytes.NewReader(gzipped(t, content)))
+	points := 0
+	for {
+		item, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Equal(t, nil, err)
+		if _, ok := item.(*TrackPoint); ok {
+			points++
+		}
+	}
+	gpx, _ := ParseWithContent(content)
+	assert.Equal(t, len(gpx.Tracks[0].Segments[0].Waypoints), points)
+
+	_, err = NewDecoder(bytes.NewReader(zipMagic)).Next()
+	assert.Equal(t, ErrArchive, err)
+}
+
+func TestValidateCompressed(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+	plain, err := ValidateWithContent(content)
+	assert.Equal(t, nil, err)
+	violations, err := ValidateWithContent(gzipped(t, content))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, plain, violations)
+}
+
+func TestWriteGzip(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	path := filepath.Join(t.TempDir(), "zoo.gpx.gz")
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteGzip(&buffer))
+	assert.Equal(t, nil, ioutil.WriteFile(path, buffer.Bytes(), 0644))
+
+	reparsed, err := ParseWithPath(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, gpx.ToXML(), reparsed.ToXML())
+}
+
+func TestParseAllZip(t *testing.T) {
+	zoo, _ := ioutil.ReadFile("testdata/St_Louis_Zoo_sample.gpx")
+	gpx10, _ := ioutil.ReadFile("testdata/gpx10_sample.gpx")
+
+	var buffer bytes.Buffer
+	w := zip.NewWriter(&buffer)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{"tracks/", nil},
+		{"tracks/zoo.gpx", zoo},
+		{"README.txt", []byte("not a track")},
+		{"__MACOSX/tracks/._zoo.gpx", []byte{0, 5, 22, 7}},
+		{"tracks/old.GPX.gz", gzipped(t, gpx10)},
+	} {
+		f, err := w.Create(file.name)
+		assert.Equal(t, nil, err)
+		f.Write(file.content)
+	}
+	assert.Equal(t, nil, w.Close())
+
+	_, err := ParseWithContent(buffer.Bytes())
+	assert.Equal(t, ErrArchive, err)
+
+	all, err := ParseAllWithReader(bytes.NewReader(buffer.Bytes()))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(all))
+	assert.Equal(t, "1.1", all[0].Version)
+	assert.Equal(t, "1.0", all[1].Version)
+
+	path := filepath.Join(t.TempDir(), "tracks.zip")
+	assert.Equal(t, nil, ioutil.WriteFile(path, buffer.Bytes(), 0644))
+	all, err = ParseAllWithPath(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(all))
+
+	// Other documents are parsed alone.
+	all, err = ParseAllWithPath("testdata/gpx10_sample.gpx.bz2")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(all))
+}
@@ -0,0 +1,251 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV columns. Speed (m/s), bearing (degrees) and grade (percent) are those
+// of the leg ending at the point, distance (meters) is from the start of the
+// track; they are derived on export and ignored on import.
+const (
+	CSV_TRACK    = "track"
+	CSV_SEGMENT  = "segment"
+	CSV_LAT      = "lat"
+	CSV_LON      = "lon"
+	CSV_ELE      = "ele"
+	CSV_TIME     = "time"
+	CSV_SPEED    = "speed"
+	CSV_DISTANCE = "distance"
+	CSV_BEARING  = "bearing"
+	CSV_GRADE    = "grade"
+)
+
+var csvColumns = []string{CSV_TRACK, CSV_SEGMENT, CSV_LAT, CSV_LON, CSV_ELE, CSV_TIME, CSV_SPEED, CSV_DISTANCE, CSV_BEARING, CSV_GRADE}
+
+// CSVOptions configures the CSV export and import. Comma is the separator,
+// e.g. '\t' for TSV. Columns are the columns written, in order. Mapping maps
+// headers read to columns, e.g. "Latitude" to CSV_LAT; headers named as a
+// column, ignoring case, need not be mapped.
+type CSVOptions struct {
+	Comma   rune
+	Columns []string
+	Mapping map[string]string
+}
+
+/*==========================================================*/
+// Static
+func DefaultCSVOptions() CSVOptions {
+	return CSVOptions{
+		Comma:   ',',
+		Columns: append([]string(nil), csvColumns...),
+	}
+}
+
+func csvFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func csvNullableFloat(value NullableFloat64) string {
+	if !value.Valid {
+		return ""
+	}
+	return csvFloat(value.Float64)
+}
+
+func (opts CSVOptions) comma() rune {
+	if opts.Comma == 0 {
+		return ','
+	}
+	return opts.Comma
+}
+
+// column returns the column of the header, or "" if it is not read.
+func (opts CSVOptions) column(header string) string {
+	header = strings.TrimSpace(strings.TrimPrefix(header, "\ufeff"))
+	if column, found := opts.Mapping[header]; found {
+		return column
+	}
+	for _, column := range []string{CSV_TRACK, CSV_SEGMENT, CSV_LAT, CSV_LON, CSV_ELE, CSV_TIME} {
+		if strings.EqualFold(header, column) {
+			return column
+		}
+	}
+	return ""
+}
+
+// ParseCSVWithReader reads track points from CSV, with a header row. The
+// lat and lon columns are required; ele and time are read if present. A
+// change of the track or segment column starts a new track or segment,
+// without them all points are in one. Empty cells are missing values, a
+// malformed value is a *ParseError at its row (the header being the first)
+// and column, with the header as Path.
+func ParseCSVWithReader(r io.Reader, opts CSVOptions) (*Gpx, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.comma()
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("gpxgo: CSV without header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	indexes := map[string]int{}
+	for i, header := range headers {
+		if column := opts.column(header); column != "" {
+			indexes[column] = i
+		}
+	}
+	_, hasLat := indexes[CSV_LAT]
+	_, hasLon := indexes[CSV_LON]
+	if !hasLat || !hasLon {
+		return nil, errors.New("gpxgo: CSV without lat and lon columns")
+	}
+
+	gpx := NewGpx()
+	var track, segment string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		wp, parseErr := csvWpt(record, indexes)
+		if parseErr != nil {
+			parseErr.Line = line
+			parseErr.Path = headers[parseErr.Column-1]
+			return nil, parseErr
+		}
+
+		value := func(column string) string {
+			if i, found := indexes[column]; found {
+				return record[i]
+			}
+			return ""
+		}
+		if len(gpx.Tracks) == 0 || value(CSV_TRACK) != track {
+			gpx.Tracks = append(gpx.Tracks, Trk{})
+			track = value(CSV_TRACK)
+		}
+		trk := &gpx.Tracks[len(gpx.Tracks)-1]
+		if len(trk.Segments) == 0 || value(CSV_SEGMENT) != segment {
+			trk.Segments = append(trk.Segments, Trkseg{})
+			segment = value(CSV_SEGMENT)
+		}
+		seg := &trk.Segments[len(trk.Segments)-1]
+		seg.Waypoints = append(seg.Waypoints, wp)
+	}
+	return gpx, nil
+}
+
+func ParseCSVWithContent(content []byte, opts CSVOptions) (*Gpx, error) {
+	return ParseCSVWithReader(bytes.NewReader(content), opts)
+}
+
+// csvWpt returns the point of a record, or the error of its column.
+func csvWpt(record []string, indexes map[string]int) (Wpt, *ParseError) {
+	var wp Wpt
+	for _, column := range []string{CSV_LAT, CSV_LON, CSV_ELE, CSV_TIME} {
+		i, found := indexes[column]
+		if !found {
+			continue
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" && column != CSV_LAT && column != CSV_LON {
+			continue
+		}
+		var err error
+		switch column {
+		case CSV_LAT:
+			wp.Lat, err = strconv.ParseFloat(value, 64)
+		case CSV_LON:
+			wp.Lon, err = strconv.ParseFloat(value, 64)
+		case CSV_ELE:
+			var ele float64
+			ele, err = strconv.ParseFloat(value, 64)
+			wp.Ele.SetValue(ele)
+		case CSV_TIME:
+			wp.Time.Time, err = ParseTime(value)
+			wp.Time.Valid = err == nil
+		}
+		if err != nil {
+			return wp, &ParseError{Column: i + 1, Err: err}
+		}
+	}
+	return wp, nil
+}
+
+/*==========================================================*/
+// Gpx
+
+// ToCSV writes the track points as CSV, with a header row of the columns.
+func (g *Gpx) ToCSV(opts CSVOptions) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := g.WriteCSV(&buffer, opts); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// WriteCSV writes the document to w as ToCSV.
+func (g *Gpx) WriteCSV(w io.Writer, opts CSVOptions) error {
+	columns := opts.Columns
+	if columns == nil {
+		columns = csvColumns
+	}
+	for _, column := range columns {
+		known := false
+		for _, csvColumn := range csvColumns {
+			known = known || column == csvColumn
+		}
+		if !known {
+			return fmt.Errorf("gpxgo: unknown CSV column %q", column)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for i := range g.Tracks {
+		var distance float64
+		for j := range g.Tracks[i].Segments {
+			for _, pd := range g.Tracks[i].Segments[j].PointsData(1) {
+				distance += pd.Distance
+				values := map[string]string{
+					CSV_TRACK:    strconv.Itoa(i),
+					CSV_SEGMENT:  strconv.Itoa(j),
+					CSV_LAT:      csvFloat(pd.Point.Lat),
+					CSV_LON:      csvFloat(pd.Point.Lon),
+					CSV_ELE:      csvNullableFloat(pd.Point.Ele),
+					CSV_SPEED:    csvNullableFloat(pd.Speed),
+					CSV_DISTANCE: csvFloat(distance),
+					CSV_GRADE:    csvNullableFloat(pd.Grade),
+				}
+				if pd.Point.Time.Valid {
+					values[CSV_TIME] = FormatTime(pd.Point.Time.Time)
+				}
+				if pd.Index > 0 {
+					values[CSV_BEARING] = csvFloat(pd.Bearing)
+				}
+				record := make([]string, len(columns))
+				for k, column := range columns {
+					record[k] = values[column]
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
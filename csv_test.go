@@ -0,0 +1,83 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func csvSample() *Gpx {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	gpx := NewGpx()
+	gpx.Tracks = []Trk{{Segments: []Trkseg{
+		{Waypoints: Waypoints{
+			{Lat: 46, Lon: 13.5, Ele: NewNullableFloat64(100), Time: NewNullableTime(start)},
+			{Lat: 46.001, Lon: 13.5, Ele: NewNullableFloat64(110), Time: NewNullableTime(start.Add(20 * time.Second))},
+		}},
+		{Waypoints: Waypoints{{Lat: 46.002, Lon: 13.5}}},
+	}}}
+	return gpx
+}
+
+func TestToCSV(t *testing.T) {
+	content, err := csvSample().ToCSV(DefaultCSVOptions())
+	assert.Equal(t, nil, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.Equal(t, "track,segment,lat,lon,ele,time,speed,distance,bearing,grade", lines[0])
+	assert.Equal(t, "0,0,46,13.5,100,2016-06-29T10:00:00Z,,0,,", lines[1])
+
+	fields := strings.Split(lines[2], ",")
+	assert.Equal(t, "2016-06-29T10:00:20Z", fields[5])
+	speed, distance, grade := fields[6], fields[7], fields[9]
+	assert.Equal(t, true, strings.HasPrefix(speed, "5.55"), speed)
+	assert.Equal(t, true, strings.HasPrefix(distance, "111.1"), distance)
+	assert.Equal(t, "0", fields[8])
+	assert.Equal(t, true, strings.HasPrefix(grade, "8.99"), grade)
+	// The distance goes on in the next segment.
+	assert.Equal(t, "0,1,46.002,13.5,,,,"+distance+",,", lines[3])
+
+	content, err = csvSample().ToCSV(CSVOptions{Comma: '\t', Columns: []string{CSV_TIME, CSV_LAT}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.HasPrefix(string(content), "time\tlat\n2016-06-29T10:00:00Z\t46\n"))
+
+	_, err = csvSample().ToCSV(CSVOptions{Columns: []string{"hr"}})
+	assert.Equal(t, `gpxgo: unknown CSV column "hr"`, err.Error())
+}
+
+func TestDefaultCSVOptions(t *testing.T) {
+	// Changing the options returned leaves the defaults unchanged.
+	opts := DefaultCSVOptions()
+	opts.Columns[0] = CSV_TIME
+	assert.Equal(t, CSV_TRACK, DefaultCSVOptions().Columns[0])
+	assert.Equal(t, CSV_TRACK, csvColumns[0])
+}
+
+func TestParseCSV(t *testing.T) {
+	content, err := csvSample().ToCSV(DefaultCSVOptions())
+	assert.Equal(t, nil, err)
+	gpx, err := ParseCSVWithContent(content, CSVOptions{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, csvSample().Tracks, gpx.Tracks)
+
+	// Mapped headers, TSV
+	opts := CSVOptions{Comma: '\t', Mapping: map[string]string{"Latitude": CSV_LAT, "Longitude": CSV_LON, "Timestamp": CSV_TIME, "Ride": CSV_TRACK}}
+	gpx, err = ParseCSVWithContent([]byte("\ufeffRide\tLatitude\tLongitude\tTimestamp\tHR\n"+
+		"a\t46\t13.5\t2016-06-29 10:00:00\t120\n"+
+		"a\t46.1\t13.6\t\t121\n"+
+		"b\t47\t14\t2016-06-30\t122\n"), opts)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(gpx.Tracks))
+	assert.Equal(t, 2, len(gpx.Tracks[0].Segments[0].Waypoints))
+	wp := gpx.Tracks[0].Segments[0].Waypoints[0]
+	assert.Equal(t, 46.0, wp.Lat)
+	assert.Equal(t, "2016-06-29T10:00:00Z", wp.Time.String())
+	assert.Equal(t, false, gpx.Tracks[0].Segments[0].Waypoints[1].Time.Valid)
+	assert.Equal(t, 47.0, gpx.Tracks[1].Segments[0].Waypoints[0].Lat)
+
+	_, err = ParseCSVWithContent([]byte("lat,lon,ele\n46,13.5,1\n46,x,2\n"), CSVOptions{})
+	assert.Equal(t, `gpxgo: line 3, column 2, lon: strconv.ParseFloat: parsing "x": invalid syntax`, err.Error())
+	_, err = ParseCSVWithContent([]byte("lat,ele\n"), CSVOptions{})
+	assert.Equal(t, "gpxgo: CSV without lat and lon columns", err.Error())
+}
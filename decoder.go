@@ -0,0 +1,376 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"golang.org/x/net/html/charset"
+	"io"
+)
+
+// TrackPoint is a track point read by a Decoder, with the index of its track
+// and segment in the document and its index in the segment.
+type TrackPoint struct {
+	Track   int
+	Segment int
+	Index   int
+	Point   Wpt
+}
+
+// Decoder reads a GPX 1.0 or 1.1 document one item at a time, so documents of
+// any size can be processed in constant memory.
+//
+// Next returns, in document order:
+//   - *Metadata, the document metadata (for GPX 1.0 the top-level fields)
+//   - *Wpt, a waypoint
+//   - *Rte, a complete route
+//   - *Trk, a track without its segments, before the points of the track
+//   - *TrackPoint, a track point
+type Decoder struct {
+	// Options applied to each item, to be set before the first call to Next
+	Options ParseOptions
+
+	d       *xml.Decoder
+	source  *decompressor
+	tracker *tokenTracker
+	started bool
+	gpx10   bool
+	version string
+	creator string
+	attrs   []xml.Attr
+
+	// GPX 1.0 metadata is spread over top-level elements
+	metadata10 *gpx10
+	pending    []interface{}
+	eof        bool
+
+	trk     interface{}
+	inTrk   bool
+	inSeg   bool
+	track   int
+	segment int
+	index   int
+}
+
+/*==========================================================*/
+// Static
+
+// NewDecoder returns a Decoder reading from r. Gzip and bzip2 compressed
+// documents are decompressed, and a zip archive is an ErrArchive error.
+func NewDecoder(r io.Reader) *Decoder {
+	source := &decompressor{r: r}
+	d := xml.NewDecoder(source)
+	d.CharsetReader = charset.NewReaderLabel
+	tracker := newTokenTracker(d, false)
+	return &Decoder{d: xml.NewTokenDecoder(tracker), source: source, tracker: tracker, track: -1}
+}
+
+/*==========================================================*/
+// Decoder
+
+// Version returns the version attribute of the document, once Next has been
+// called.
+func (dec *Decoder) Version() string {
+	return dec.version
+}
+
+// Creator returns the creator attribute of the document, once Next has been
+// called.
+func (dec *Decoder) Creator() string {
+	return dec.creator
+}
+
+// Warnings returns the points skipped so far by Options.Lenient.
+func (dec *Decoder) Warnings() []*ParseError {
+	return dec.tracker.warnings
+}
+
+// Next returns the next item of the document, or io.EOF at its end. Other
+// errors are *ParseError.
+func (dec *Decoder) Next() (interface{}, error) {
+	item, err := dec.next()
+	if err != nil && err == dec.source.err {
+		// Not an error of the document
+		return nil, err
+	}
+	if err != nil {
+		return nil, dec.tracker.wrap(err)
+	}
+	dec.Options.applyItem(item, dec.attrs)
+	return item, nil
+}
+
+func (dec *Decoder) next() (interface{}, error) {
+	if len(dec.pending) > 0 {
+		item := dec.pending[0]
+		dec.pending = dec.pending[1:]
+		return item, nil
+	}
+	if dec.eof {
+		return nil, io.EOF
+	}
+	if !dec.started {
+		if err := dec.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		token, err := dec.d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			item, err := dec.startElement(t)
+			if err != nil || item != nil {
+				return item, err
+			}
+		case xml.EndElement:
+			switch {
+			case dec.inSeg && t.Name.Local == "trkseg":
+				dec.inSeg = false
+			case dec.inTrk && t.Name.Local == "trk":
+				dec.inTrk = false
+				// A track without segments
+				if dec.trk != nil {
+					return dec.flushTrk(), nil
+				}
+			case !dec.inTrk && t.Name.Local == "gpx":
+				dec.eof = true
+				if metadata := dec.flushMetadata10(); metadata != nil {
+					return metadata, nil
+				}
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+func (dec *Decoder) start() error {
+	dec.tracker.lenient = dec.Options.Lenient
+	for {
+		token, err := dec.d.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "gpx" {
+				return xml.UnmarshalError("expected element type <gpx> but have <" + start.Name.Local + ">")
+			}
+			dec.started = true
+			dec.gpx10 = isGpx10(start)
+			if dec.gpx10 {
+				dec.metadata10 = new(gpx10)
+			}
+			var attrs []xml.Attr
+			for _, attr := range start.Attr {
+				switch attr.Name {
+				case xml.Name{Local: "version"}:
+					dec.version = attr.Value
+				case xml.Name{Local: "creator"}:
+					dec.creator = attr.Value
+				case xml.Name{Local: "xmlns"}:
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+			dec.attrs = qualifyAttrs(attrs)
+			return nil
+		}
+	}
+}
+
+func (dec *Decoder) startElement(start xml.StartElement) (interface{}, error) {
+	if dec.inSeg {
+		if start.Name.Local != "trkpt" {
+			return nil, dec.d.Skip()
+		}
+		wp, err := dec.decodeWpt(start)
+		if err != nil {
+			return nil, err
+		}
+		dec.index++
+		return &TrackPoint{Track: dec.track, Segment: dec.segment, Index: dec.index, Point: wp}, nil
+	}
+
+	if dec.inTrk {
+		if start.Name.Local == "trkseg" {
+			dec.inSeg = true
+			dec.segment++
+			dec.index = -1
+			if dec.trk != nil {
+				return dec.flushTrk(), nil
+			}
+			return nil, nil
+		}
+		return nil, dec.decodeTrkField(start)
+	}
+
+	var item interface{}
+	switch start.Name.Local {
+	case "metadata":
+		metadata := new(Metadata)
+		if err := dec.d.DecodeElement(metadata, &start); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	case "wpt":
+		wp, err := dec.decodeWpt(start)
+		if err != nil {
+			return nil, err
+		}
+		item = &wp
+	case "rte":
+		if dec.gpx10 {
+			r10 := new(rte10)
+			if err := dec.d.DecodeElement(r10, &start); err != nil {
+				return nil, err
+			}
+			rte := r10.toRte()
+			item = &rte
+		} else {
+			rte := new(Rte)
+			if err := dec.d.DecodeElement(rte, &start); err != nil {
+				return nil, err
+			}
+			item = rte
+		}
+	case "trk":
+		dec.inTrk = true
+		dec.track++
+		dec.segment = -1
+		if dec.gpx10 {
+			dec.trk = new(trk10)
+		} else {
+			dec.trk = &Trk{XMLName: start.Name}
+		}
+	default:
+		if dec.gpx10 {
+			return nil, dec.decodeMetadata10Field(start)
+		}
+		return nil, dec.d.Skip()
+	}
+
+	// The GPX 1.0 metadata is complete once the first waypoint, route or
+	// track starts.
+	if metadata := dec.flushMetadata10(); metadata != nil {
+		if item != nil {
+			dec.pending = append(dec.pending, item)
+		}
+		return metadata, nil
+	}
+	return item, nil
+}
+
+func (dec *Decoder) decodeWpt(start xml.StartElement) (Wpt, error) {
+	if dec.gpx10 {
+		var w10 wpt10
+		if err := dec.d.DecodeElement(&w10, &start); err != nil {
+			return Wpt{}, err
+		}
+		return w10.toWpt(), nil
+	}
+	var wp Wpt
+	err := dec.d.DecodeElement(&wp, &start)
+	return wp, err
+}
+
+func (dec *Decoder) decodeTrkField(start xml.StartElement) error {
+	var v interface{}
+	switch trk := dec.trk.(type) {
+	case *Trk:
+		switch start.Name.Local {
+		case "name":
+			v = &trk.Name
+		case "cmt":
+			v = &trk.Cmt
+		case "desc":
+			v = &trk.Desc
+		case "src":
+			v = &trk.Src
+		case "link":
+			trk.Link = append(trk.Link, Link{})
+			v = &trk.Link[len(trk.Link)-1]
+		case "number":
+			v = &trk.Number
+		case "type":
+			v = &trk.Type
+		case "extensions":
+			v = &trk.Extensions
+		}
+	case *trk10:
+		switch start.Name.Local {
+		case "name":
+			v = &trk.Name
+		case "cmt":
+			v = &trk.Cmt
+		case "desc":
+			v = &trk.Desc
+		case "src":
+			v = &trk.Src
+		case "url":
+			v = &trk.URL
+		case "urlname":
+			v = &trk.URLName
+		case "number":
+			v = &trk.Number
+		}
+	}
+	if v == nil {
+		return dec.d.Skip()
+	}
+	return dec.d.DecodeElement(v, &start)
+}
+
+func (dec *Decoder) decodeMetadata10Field(start xml.StartElement) error {
+	var v interface{}
+	g10 := dec.metadata10
+	if g10 != nil {
+		switch start.Name.Local {
+		case "name":
+			v = &g10.Name
+		case "desc":
+			v = &g10.Desc
+		case "author":
+			v = &g10.Author
+		case "email":
+			v = &g10.Email
+		case "url":
+			v = &g10.URL
+		case "urlname":
+			v = &g10.URLName
+		case "time":
+			v = &g10.Time
+		case "keywords":
+			v = &g10.Keywords
+		case "bounds":
+			v = &g10.Bounds
+		}
+	}
+	if v == nil {
+		return dec.d.Skip()
+	}
+	return dec.d.DecodeElement(v, &start)
+}
+
+func (dec *Decoder) flushMetadata10() *Metadata {
+	if dec.metadata10 == nil {
+		return nil
+	}
+	metadata := dec.metadata10.toMetadata()
+	dec.metadata10 = nil
+	return metadata
+}
+
+func (dec *Decoder) flushTrk() *Trk {
+	var trk *Trk
+	switch t := dec.trk.(type) {
+	case *Trk:
+		trk = t
+	case *trk10:
+		converted := t.toTrk()
+		trk = &converted
+	}
+	dec.trk = nil
+	return trk
+}
@@ -0,0 +1,102 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func decodeAll(t *testing.T, path string) (*Decoder, []interface{}) {
+	file, err := os.Open(path)
+	assert.Equal(t, nil, err)
+	defer file.Close()
+
+	var items []interface{}
+	dec := NewDecoder(file)
+	for {
+		item, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Equal(t, nil, err)
+		items = append(items, item)
+	}
+	return dec, items
+}
+
+func TestDecoder(t *testing.T) {
+	path := "testdata/St_Louis_Zoo_sample.gpx"
+	gpx, err := ParseWithPath(path)
+	assert.Equal(t, nil, err)
+
+	dec, items := decodeAll(t, path)
+	assert.Equal(t, "1.1", dec.Version())
+	assert.Equal(t, "Geovative Solutions GeoTours", dec.Creator())
+
+	var (
+		waypoints Waypoints
+		tracks    []Trk
+	)
+	for _, item := range items {
+		switch v := item.(type) {
+		case *Metadata:
+			assert.Equal(t, gpx.Metadata, v)
+		case *Wpt:
+			waypoints = append(waypoints, *v)
+		case *Trk:
+			assert.Equal(t, 0, len(v.Segments))
+			tracks = append(tracks, *v)
+		case *TrackPoint:
+			assert.Equal(t, len(tracks)-1, v.Track)
+			trk := &tracks[v.Track]
+			if v.Segment == len(trk.Segments) {
+				trk.Segments = append(trk.Segments, Trkseg{})
+			}
+			seg := &trk.Segments[v.Segment]
+			assert.Equal(t, len(seg.Waypoints), v.Index)
+			seg.Waypoints = append(seg.Waypoints, v.Point)
+		}
+	}
+	assert.Equal(t, gpx.Waypoints, waypoints)
+	assert.Equal(t, len(gpx.Tracks), len(tracks))
+	for i, trk := range tracks {
+		assert.Equal(t, gpx.Tracks[i].Name, trk.Name)
+		for j, seg := range trk.Segments {
+			assert.Equal(t, gpx.Tracks[i].Segments[j].Waypoints, seg.Waypoints)
+		}
+	}
+}
+
+func TestDecoderGpx10(t *testing.T) {
+	path := "testdata/gpx10_sample.gpx"
+	gpx, err := ParseWithPath(path)
+	assert.Equal(t, nil, err)
+
+	dec, items := decodeAll(t, path)
+	assert.Equal(t, "1.0", dec.Version())
+	assert.Equal(t, 6, len(items))
+	assert.Equal(t, gpx.Metadata, items[0])
+	assert.Equal(t, &gpx.Waypoints[0], items[1])
+	assert.Equal(t, &gpx.Routes[0], items[2])
+	trk := gpx.Tracks[0]
+	trk.Segments = nil
+	assert.Equal(t, &trk, items[3])
+	assert.Equal(t, &TrackPoint{Track: 0, Segment: 0, Index: 1, Point: gpx.Tracks[0].Segments[0].Waypoints[1]}, items[5])
+}
+
+func TestDecoderErrors(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader(`<kml></kml>`)).Next()
+	assert.NotEqual(t, nil, err)
+
+	dec := NewDecoder(strings.NewReader(`<gpx version="1.1"><trk><trkseg><trkpt lat="1" lon="2"></trkpt><trkpt lat="x"`))
+	item, err := dec.Next()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(item.(*Trk).Segments))
+	item, err = dec.Next()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2.0, item.(*TrackPoint).Point.Lon)
+	_, err = dec.Next()
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,328 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+const (
+	encoderStart = iota
+	encoderWaypoints
+	encoderRoutes
+	encoderTracks
+	encoderClosed
+)
+
+// Encoder writes a GPX document to an io.Writer piece by piece, in the
+// element order required by the schema: Begin, then waypoints, routes and
+// tracks, then Close. The document is written as GPX 1.0 if the Gpx given to
+// Begin has Version "1.0", as GPX 1.1 otherwise.
+type Encoder struct {
+	w          io.Writer
+	e          *xml.Encoder
+	gpx10      bool
+	extensions *Extensions
+	state      int
+	inTrk      bool
+	inSeg      bool
+	seg        *Trkseg
+
+	// the unknown elements of the open elements
+	gpxUnknown unknownEncoder
+	trkUnknown unknownEncoder
+	segUnknown unknownEncoder
+}
+
+/*==========================================================*/
+// Static
+func NewEncoder(w io.Writer) *Encoder {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &Encoder{w: w, e: e}
+}
+
+func startElement(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+/*==========================================================*/
+// Encoder
+
+// Indent sets the indentation, see xml.Encoder.Indent. The default matches
+// Gpx.ToXML.
+func (enc *Encoder) Indent(prefix, indent string) {
+	enc.e.Indent(prefix, indent)
+}
+
+// Begin writes the XML declaration, the <gpx> element with the attributes of
+// g and its metadata. The content of g is not written, and the extensions of
+// g are written by Close.
+func (enc *Encoder) Begin(g *Gpx) error {
+	if enc.state != encoderStart {
+		return errors.New("gpxgo: Begin called twice")
+	}
+	if _, err := io.WriteString(enc.w, xml.Header); err != nil {
+		return err
+	}
+
+	header := *g
+	header.Waypoints, header.Routes, header.Tracks = nil, nil, nil
+	enc.state = encoderWaypoints
+	enc.gpx10 = g.Version == "1.0"
+	enc.gpxUnknown = unknownEncoder{unknown: g.Unknown}
+	var v interface{} = &header
+	if enc.gpx10 {
+		v = header.toGpx10()
+	} else {
+		enc.extensions = g.Extensions
+	}
+	start, err := encodeAttrs(startElement("gpx"), v)
+	if err != nil {
+		return err
+	}
+	if err := enc.e.EncodeToken(start); err != nil {
+		return err
+	}
+	return enc.gpxUnknown.encodeChildren(enc.e, v, "wpt", "rte", "trk", "extensions")
+}
+
+func (enc *Encoder) advance(state int, what string) error {
+	if enc.state == encoderStart {
+		return errors.New("gpxgo: " + what + " before Begin")
+	}
+	if enc.state == encoderClosed {
+		return errors.New("gpxgo: " + what + " after Close")
+	}
+	if enc.state > state {
+		return errors.New("gpxgo: " + what + " out of order")
+	}
+	if state != encoderTracks {
+		if err := enc.endTrack(); err != nil {
+			return err
+		}
+	}
+	enc.state = state
+	return nil
+}
+
+func (enc *Encoder) EncodeWaypoint(wp *Wpt) error {
+	if err := enc.advance(encoderWaypoints, "waypoint"); err != nil {
+		return err
+	}
+	if err := enc.gpxUnknown.next(enc.e); err != nil {
+		return err
+	}
+	if enc.gpx10 {
+		return enc.e.EncodeElement(wp.toWpt10(false), startElement("wpt"))
+	}
+	return enc.e.EncodeElement(wp, startElement("wpt"))
+}
+
+func (enc *Encoder) EncodeRoute(r *Rte) error {
+	if err := enc.advance(encoderRoutes, "route"); err != nil {
+		return err
+	}
+	if err := enc.gpxUnknown.next(enc.e); err != nil {
+		return err
+	}
+	if enc.gpx10 {
+		return enc.e.EncodeElement(r.toRte10(), startElement("rte"))
+	}
+	return enc.e.EncodeElement(r, startElement("rte"))
+}
+
+// EncodeTrack writes a complete track.
+func (enc *Encoder) EncodeTrack(t *Trk) error {
+	if err := enc.StartTrack(t); err != nil {
+		return err
+	}
+	for i := range t.Segments {
+		seg := &t.Segments[i]
+		if err := enc.startSegment(seg); err != nil {
+			return err
+		}
+		for j := range seg.Waypoints {
+			if err := enc.EncodeTrackPoint(&seg.Waypoints[j]); err != nil {
+				return err
+			}
+		}
+		if err := enc.endSegment(); err != nil {
+			return err
+		}
+	}
+	return enc.endTrack()
+}
+
+// StartTrack closes the current track, if any, and starts a new one with the
+// fields of t. The segments of t are not written.
+func (enc *Encoder) StartTrack(t *Trk) error {
+	if err := enc.advance(encoderTracks, "track"); err != nil {
+		return err
+	}
+	if err := enc.endTrack(); err != nil {
+		return err
+	}
+	if err := enc.gpxUnknown.next(enc.e); err != nil {
+		return err
+	}
+	if err := enc.e.EncodeToken(startElement("trk")); err != nil {
+		return err
+	}
+	enc.inTrk = true
+	enc.trkUnknown = unknownEncoder{unknown: t.Unknown}
+	if enc.gpx10 {
+		header := *t
+		header.Segments = nil
+		return enc.trkUnknown.encodeChildren(enc.e, header.toTrk10(), "trkseg")
+	}
+	return enc.trkUnknown.encodeChildren(enc.e, t, "trkseg")
+}
+
+// StartSegment closes the current segment, if any, and starts a new one in
+// the current track.
+func (enc *Encoder) StartSegment() error {
+	return enc.startSegment(nil)
+}
+
+// startSegment starts a segment, to be ended with the extensions and unknown
+// elements of seg if not nil.
+func (enc *Encoder) startSegment(seg *Trkseg) error {
+	if !enc.inTrk || enc.state == encoderClosed {
+		return errors.New("gpxgo: segment outside of a track")
+	}
+	if err := enc.endSegment(); err != nil {
+		return err
+	}
+	if err := enc.trkUnknown.next(enc.e); err != nil {
+		return err
+	}
+	enc.inSeg = true
+	enc.seg = seg
+	enc.segUnknown = unknownEncoder{}
+	if seg != nil {
+		enc.segUnknown.unknown = seg.Unknown
+	}
+	return enc.e.EncodeToken(startElement("trkseg"))
+}
+
+// EncodeTrackPoint writes a point to the current segment, starting one if
+// needed.
+func (enc *Encoder) EncodeTrackPoint(wp *Wpt) error {
+	if !enc.inTrk || enc.state == encoderClosed {
+		return errors.New("gpxgo: track point outside of a track")
+	}
+	if !enc.inSeg {
+		if err := enc.StartSegment(); err != nil {
+			return err
+		}
+	}
+	if err := enc.segUnknown.next(enc.e); err != nil {
+		return err
+	}
+	if enc.gpx10 {
+		return enc.e.EncodeElement(wp.toWpt10(true), startElement("trkpt"))
+	}
+	return enc.e.EncodeElement(wp, startElement("trkpt"))
+}
+
+// endSegment closes the current segment, if any, with the extensions of the
+// segment started.
+func (enc *Encoder) endSegment() error {
+	if !enc.inSeg {
+		return nil
+	}
+	enc.inSeg = false
+	var extensions *Extensions
+	if enc.seg != nil {
+		extensions = enc.seg.Extensions
+	}
+	if err := enc.encodeTail(extensions, &enc.segUnknown); err != nil {
+		return err
+	}
+	return enc.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "trkseg"}})
+}
+
+func (enc *Encoder) endTrack() error {
+	if !enc.inTrk {
+		return nil
+	}
+	if err := enc.endSegment(); err != nil {
+		return err
+	}
+	enc.inTrk = false
+	if err := enc.trkUnknown.flush(enc.e); err != nil {
+		return err
+	}
+	return enc.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "trk"}})
+}
+
+// encodeTail writes the extensions, for GPX 1.1, and the unknown elements
+// left that end an element.
+func (enc *Encoder) encodeTail(extensions *Extensions, unknown *unknownEncoder) error {
+	if extensions != nil && !enc.gpx10 {
+		if err := unknown.next(enc.e); err != nil {
+			return err
+		}
+		if err := enc.e.EncodeElement(extensions, startElement("extensions")); err != nil {
+			return err
+		}
+	}
+	return unknown.flush(enc.e)
+}
+
+// Flush writes buffered data to the underlying writer, e.g. after each point
+// of a live log.
+func (enc *Encoder) Flush() error {
+	return enc.e.Flush()
+}
+
+// Close ends all open elements and flushes the document. It does not close
+// the underlying writer.
+func (enc *Encoder) Close() error {
+	if err := enc.advance(encoderTracks, "Close"); err != nil {
+		return err
+	}
+	if err := enc.endTrack(); err != nil {
+		return err
+	}
+	enc.state = encoderClosed
+	if err := enc.encodeTail(enc.extensions, &enc.gpxUnknown); err != nil {
+		return err
+	}
+	if err := enc.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "gpx"}}); err != nil {
+		return err
+	}
+	return enc.e.Flush()
+}
+
+// Encode writes the complete document g.
+func (enc *Encoder) Encode(g *Gpx) error {
+	if err := enc.Begin(g); err != nil {
+		return err
+	}
+	for i := range g.Waypoints {
+		if err := enc.EncodeWaypoint(&g.Waypoints[i]); err != nil {
+			return err
+		}
+	}
+	for i := range g.Routes {
+		if err := enc.EncodeRoute(&g.Routes[i]); err != nil {
+			return err
+		}
+	}
+	for i := range g.Tracks {
+		if err := enc.EncodeTrack(&g.Tracks[i]); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+/*==========================================================*/
+// Gpx
+
+// WriteXML writes the document to w like ToXML, returning any error.
+func (g *Gpx) WriteXML(w io.Writer) error {
+	return NewEncoder(w).Encode(g)
+}
@@ -0,0 +1,82 @@
+package gpxgo
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoderMatchesToXML(t *testing.T) {
+	for _, path := range []string{"testdata/St_Louis_Zoo_sample.gpx", "testdata/gpx10_sample.gpx"} {
+		gpx, err := ParseWithPath(path)
+		assert.Equal(t, nil, err)
+
+		var buffer bytes.Buffer
+		err = gpx.WriteXML(&buffer)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, string(gpx.ToXML()), buffer.String())
+	}
+}
+
+func TestEncoderIncremental(t *testing.T) {
+	var buffer bytes.Buffer
+	enc := NewEncoder(&buffer)
+	gpx := NewGpx()
+
+	assert.Equal(t, nil, enc.Begin(gpx))
+	wp := Wpt{Lat: 1.1111, Lon: 9.9999, Ele: NewNullableFloat64(1111)}
+	assert.Equal(t, nil, enc.EncodeWaypoint(&wp))
+	gpx.Waypoints = append(gpx.Waypoints, wp)
+
+	trk := Trk{Name: "live"}
+	assert.Equal(t, nil, enc.StartTrack(&trk))
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			assert.Equal(t, nil, enc.StartSegment())
+			trk.Segments = append(trk.Segments, Trkseg{})
+		} else if i == 0 {
+			trk.Segments = append(trk.Segments, Trkseg{})
+		}
+		point := Wpt{Lat: 32.1234 + float64(i), Lon: 121.1233}
+		assert.Equal(t, nil, enc.EncodeTrackPoint(&point))
+		assert.Equal(t, nil, enc.Flush())
+		seg := &trk.Segments[len(trk.Segments)-1]
+		seg.Waypoints = append(seg.Waypoints, point)
+	}
+	assert.Equal(t, nil, enc.Close())
+	gpx.Tracks = append(gpx.Tracks, trk)
+
+	assert.Equal(t, string(gpx.ToXML()), buffer.String())
+}
+
+func TestEncoderErrors(t *testing.T) {
+	var buffer bytes.Buffer
+	enc := NewEncoder(&buffer)
+	assert.NotEqual(t, nil, enc.EncodeWaypoint(&Wpt{}))
+	assert.Equal(t, nil, enc.Begin(NewGpx()))
+	assert.NotEqual(t, nil, enc.EncodeTrackPoint(&Wpt{}))
+	assert.Equal(t, nil, enc.EncodeTrack(&Trk{}))
+	assert.NotEqual(t, nil, enc.EncodeWaypoint(&Wpt{}))
+	assert.NotEqual(t, nil, enc.EncodeRoute(&Rte{}))
+	assert.Equal(t, nil, enc.Close())
+	assert.NotEqual(t, nil, enc.EncodeTrack(&Trk{}))
+
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, nil, gpx.WriteXML(failingWriter{}))
+
+	// ToXML gives nil for a document WriteXML fails to write.
+	wp := Wpt{Lat: 1, Lon: 2}
+	wp.Extensions = wp.Extensions.Set("urn:test:chan", "chan", make(chan int))
+	gpx.Waypoints = append(gpx.Waypoints, wp)
+	buffer.Reset()
+	assert.NotEqual(t, nil, gpx.WriteXML(&buffer))
+	assert.Equal(t, []byte(nil), gpx.ToXML())
+}
@@ -0,0 +1,211 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ParseError is an error of a document, at Line and Column (from 1) of the
+// start of the element at Path, e.g. trk[0].trkseg[1].trkpt[12]. Path is
+// empty for <gpx> itself. Err is the cause, e.g. a *strconv.NumError for a
+// malformed coordinate or a *xml.SyntaxError for a truncated document.
+type ParseError struct {
+	Line   int
+	Column int
+	Path   string
+	Err    error
+}
+
+// an element read by a tokenTracker
+type trackedElement struct {
+	name   string
+	path   string
+	line   int
+	column int
+	counts map[string]int
+}
+
+// tokenTracker reads the tokens of d, keeping the path and position of the
+// elements so that errors can be located. If lenient, the points that cannot
+// be decoded are dropped and recorded in warnings.
+type tokenTracker struct {
+	d        *xml.Decoder
+	lenient  bool
+	gpx10    bool
+	stack    []*trackedElement
+	last     *trackedElement
+	pending  []xml.Token
+	warnings []*ParseError
+}
+
+// tokenSlice is a xml.TokenReader of tokens already read.
+type tokenSlice []xml.Token
+
+// The parent of each kind of point
+var pointParents = map[string]string{
+	"wpt":   "gpx",
+	"rtept": "rte",
+	"trkpt": "trkseg",
+}
+
+/*==========================================================*/
+// Static
+func newTokenTracker(d *xml.Decoder, lenient bool) *tokenTracker {
+	return &tokenTracker{d: d, lenient: lenient}
+}
+
+// repeatable returns whether the name is of an element that may appear more
+// than once in its parent, and so is indexed in paths.
+func repeatable(name string) bool {
+	for _, sequence := range gpxSequences {
+		for _, child := range sequence {
+			if child == name+"*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*==========================================================*/
+// ParseError
+func (e *ParseError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("gpxgo: line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("gpxgo: line %d, column %d, %s: %v", e.Line, e.Column, e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+/*==========================================================*/
+// tokenTracker
+func (t *tokenTracker) Token() (xml.Token, error) {
+	if len(t.pending) > 0 {
+		token := t.pending[0]
+		t.pending = t.pending[1:]
+		return token, nil
+	}
+	token, err := t.next()
+	if err != nil {
+		return nil, err
+	}
+	if start, ok := token.(xml.StartElement); ok && t.lenient && t.inPoint() {
+		return t.checkPoint(start)
+	}
+	return token, nil
+}
+
+func (t *tokenTracker) next() (xml.Token, error) {
+	line, column := t.d.InputPos()
+	token, err := t.d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := token.(type) {
+	case xml.StartElement:
+		name := tok.Name.Local
+		element := &trackedElement{name: name, line: line, column: column, counts: map[string]int{}}
+		if len(t.stack) == 0 {
+			t.gpx10 = isGpx10(tok)
+		} else {
+			parent := t.stack[len(t.stack)-1]
+			element.path = joinPath(parent.path, name)
+			if repeatable(name) {
+				element.path = indexPath(parent.path, name, parent.counts[name])
+			}
+			parent.counts[name]++
+		}
+		t.stack = append(t.stack, element)
+		t.last = element
+	case xml.EndElement:
+		if len(t.stack) > 0 {
+			t.last = t.stack[len(t.stack)-1]
+			t.stack = t.stack[:len(t.stack)-1]
+		}
+	}
+	return token, nil
+}
+
+// inPoint returns whether the last element started is a point.
+func (t *tokenTracker) inPoint() bool {
+	if len(t.stack) < 2 {
+		return false
+	}
+	parent, found := pointParents[t.stack[len(t.stack)-1].name]
+	return found && t.stack[len(t.stack)-2].name == parent
+}
+
+// checkPoint reads the whole point started by start and returns its first
+// token, or, if it cannot be decoded, records a warning and returns the
+// token following it.
+func (t *tokenTracker) checkPoint(start xml.StartElement) (xml.Token, error) {
+	element := t.last
+	tokens := []xml.Token{xml.CopyToken(start)}
+	for depth := 1; depth > 0; {
+		token, err := t.next()
+		if err != nil {
+			return nil, err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+
+	var point interface{} = new(Wpt)
+	if t.gpx10 {
+		point = new(wpt10)
+	}
+	reader := tokenSlice(tokens)
+	if err := xml.NewTokenDecoder(&reader).Decode(point); err != nil {
+		t.warnings = append(t.warnings, element.locate(err))
+		return t.Token()
+	}
+	t.pending = tokens[1:]
+	return tokens[0], nil
+}
+
+// wrap locates err: syntax errors at the current position, other errors at
+// the last element read.
+func (t *tokenTracker) wrap(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	if _, ok := err.(*xml.SyntaxError); ok || t.last == nil {
+		line, column := t.d.InputPos()
+		path := ""
+		if len(t.stack) > 0 {
+			path = t.stack[len(t.stack)-1].path
+		}
+		return &ParseError{Line: line, Column: column, Path: path, Err: err}
+	}
+	return t.last.locate(err)
+}
+
+/*==========================================================*/
+// trackedElement
+func (element *trackedElement) locate(err error) *ParseError {
+	return &ParseError{Line: element.line, Column: element.column, Path: element.path, Err: err}
+}
+
+/*==========================================================*/
+// tokenSlice
+func (s *tokenSlice) Token() (xml.Token, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	token := (*s)[0]
+	*s = (*s)[1:]
+	return token, nil
+}
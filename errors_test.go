@@ -0,0 +1,104 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/bmizerany/assert"
+	"strconv"
+	"testing"
+)
+
+var malformedDocument = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="test">
+  <wpt lat="1" lon="2"><name>A</name></wpt>
+  <wpt lat="1" lon="x"><name>B</name></wpt>
+  <rte><rtept lat="1" lon="2"><ele>?</ele></rtept><rtept lat="3" lon="4"/></rte>
+  <trk>
+    <trkseg><trkpt lat="1" lon="2"/></trkseg>
+    <trkseg>
+      <trkpt lat="1" lon="2"/>
+      <trkpt lat="north" lon="2"/>
+      <trkpt lat="5" lon="6"/>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+const truncatedDocument = "<gpx version=\"1.1\">\n<trk><trkseg>\n<trkpt lat=\"1\" lon=\"2\"/>\n<trkpt lat=\"3\" lon=\"4\"><ele>5"
+
+func TestParseErrorLocation(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		line    int
+		column  int
+		path    string
+	}{
+		{string(malformedDocument), 4, 3, "wpt[1]"},
+		{"<gpx version=\"1.1\">\n <rte><rtept lat=\"1\" lon=\"2\">\n  <ele>?</ele></rtept></rte></gpx>", 3, 3, "rte[0].rtept[0].ele"},
+		{"<gpx version=\"1.1\">\n <trk><trkseg><trkpt lat=\"1\" lon=\"2\">\n  <time>noon</time></trkpt></trkseg></trk></gpx>", 3, 3, "trk[0].trkseg[0].trkpt[0].time"},
+		{"<gpx version=\"1.0\">\n<wpt lat=\"1\" lon=\"2\"/>\n<wpt lat=\"1\" lon=\"2\"><course>x</course></wpt></gpx>", 3, 22, "wpt[1].course"},
+	} {
+		_, err := ParseWithContent([]byte(test.content))
+		parseErr, ok := err.(*ParseError)
+		assert.Equal(t, true, ok, err)
+		assert.Equal(t, test.line, parseErr.Line, test.path)
+		assert.Equal(t, test.column, parseErr.Column, test.path)
+		assert.Equal(t, test.path, parseErr.Path)
+	}
+
+	_, err := ParseWithContent(malformedDocument)
+	var numErr *strconv.NumError
+	assert.Equal(t, true, errors.As(err, &numErr))
+	assert.Equal(t, `gpxgo: line 4, column 3, wpt[1]: strconv.ParseFloat: parsing "x": invalid syntax`, err.Error())
+}
+
+func TestParseErrorTruncated(t *testing.T) {
+	for _, opts := range []ParseOptions{{}, {Lenient: true}} {
+		_, err := ParseWithOptions(bytes.NewReader([]byte(truncatedDocument)), opts)
+		var syntaxErr *xml.SyntaxError
+		assert.Equal(t, true, errors.As(err, &syntaxErr))
+		assert.Equal(t, "trk[0].trkseg[0].trkpt[1].ele", err.(*ParseError).Path)
+		assert.Equal(t, 4, err.(*ParseError).Line)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	gpx, err := ParseWithOptions(bytes.NewReader(malformedDocument), ParseOptions{Lenient: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(gpx.Waypoints))
+	assert.Equal(t, "A", gpx.Waypoints[0].Name)
+	assert.Equal(t, 1, len(gpx.Routes[0].Waypoints))
+	assert.Equal(t, 3.0, gpx.Routes[0].Waypoints[0].Lat)
+	assert.Equal(t, 2, len(gpx.Tracks[0].Segments[1].Waypoints))
+	assert.Equal(t, 5.0, gpx.Tracks[0].Segments[1].Waypoints[1].Lat)
+
+	var paths []string
+	for _, warning := range gpx.Warnings {
+		paths = append(paths, warning.Path)
+	}
+	assert.Equal(t, []string{"wpt[1]", "rte[0].rtept[0]", "trk[0].trkseg[1].trkpt[1]"}, paths)
+	assert.Equal(t, 10, gpx.Warnings[2].Line)
+}
+
+func TestDecoderLenient(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(malformedDocument))
+	_, err := dec.Next()
+	assert.Equal(t, nil, err)
+	_, err = dec.Next()
+	assert.Equal(t, "wpt[1]", err.(*ParseError).Path)
+
+	dec = NewDecoder(bytes.NewReader(malformedDocument))
+	dec.Options.Lenient = true
+	var points int
+	for {
+		item, err := dec.Next()
+		if err != nil {
+			break
+		}
+		if _, ok := item.(*TrackPoint); ok {
+			points++
+		}
+	}
+	assert.Equal(t, 3, points)
+	assert.Equal(t, 3, len(dec.Warnings()))
+}
@@ -0,0 +1,471 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const XML_NAMESPACE = "http://www.w3.org/XML/1998/namespace"
+
+// Extensions is the content of an <extensions> element, one Extension per
+// child element in document order.
+type Extensions struct {
+	Items []Extension
+}
+
+// Extension is an element of <extensions>. Elements whose namespace and
+// name have been registered with RegisterExtension are decoded into Value, a
+// pointer to the registered type. Other elements, and registered ones that
+// cannot be decoded, have a nil Value and are kept as XML, written back
+// unchanged.
+type Extension struct {
+	Name  xml.Name
+	Value interface{}
+	raw   []xml.Token
+	// the error decoding a registered element
+	err error
+	// preferred prefixes of namespaces, by ParseOptions.Preserve
+	prefixes map[string]string
+	// for unknown elements, 1 + the number of known elements before it in its
+	// parent; 0 to write it last
+	position int
+}
+
+type extensionType struct {
+	typ    reflect.Type
+	prefix string
+}
+
+var (
+	extensionsLock    sync.RWMutex
+	extensionTypes    = map[xml.Name]extensionType{}
+	extensionPrefixes = map[string]string{}
+)
+
+/*==========================================================*/
+// Static
+
+// RegisterExtension registers the type of v, a struct or a pointer to one,
+// for the extension element local in namespace. Extensions decoded afterwards
+// hold such elements as a new value of that type, and write them back with
+// prefix. It is meant to be called from init functions.
+func RegisterExtension(namespace, local, prefix string, v interface{}) {
+	typ := reflect.TypeOf(v)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	extensionsLock.Lock()
+	defer extensionsLock.Unlock()
+	extensionTypes[xml.Name{Space: namespace, Local: local}] = extensionType{typ: typ, prefix: prefix}
+	if prefix != "" {
+		extensionPrefixes[namespace] = prefix
+	}
+}
+
+func lookupExtension(name xml.Name) (extensionType, bool) {
+	extensionsLock.RLock()
+	defer extensionsLock.RUnlock()
+	et, found := extensionTypes[name]
+	return et, found
+}
+
+func namespacePrefix(namespace string) string {
+	extensionsLock.RLock()
+	defer extensionsLock.RUnlock()
+	return extensionPrefixes[namespace]
+}
+
+func decodeExtension(d *xml.Decoder, start xml.StartElement) (Extension, error) {
+	item := Extension{Name: start.Name, raw: []xml.Token{start.Copy()}}
+	for depth := 1; depth > 0; {
+		token, err := d.Token()
+		if err != nil {
+			return item, err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.ProcInst, xml.Directive:
+			continue
+		}
+		item.raw = append(item.raw, xml.CopyToken(token))
+	}
+
+	if et, found := lookupExtension(start.Name); found {
+		v := reflect.New(et.typ).Interface()
+		reader := tokenSlice(item.raw)
+		if err := xml.NewTokenDecoder(&reader).Decode(v); err != nil {
+			item.err = fmt.Errorf("gpxgo: extension %s: %w", start.Name.Local, err)
+			return item, nil
+		}
+		item.Value, item.raw = v, nil
+	}
+	return item, nil
+}
+
+// marshalExtension writes v as the element name, with the preferred prefix
+// of its namespace, or else the registered one, declared on the element
+// itself.
+func marshalExtension(name xml.Name, v interface{}, preferred map[string]string) (string, error) {
+	var buffer bytes.Buffer
+	e := xml.NewEncoder(&buffer)
+	if err := e.EncodeElement(v, startElement(name.Local)); err != nil {
+		return "", err
+	}
+	if err := e.Flush(); err != nil {
+		return "", err
+	}
+	if name.Space == "" {
+		return buffer.String(), nil
+	}
+
+	prefix, found := preferred[name.Space]
+	if !found {
+		prefix = namespacePrefix(name.Space)
+	}
+	declaration := xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: name.Space}
+	if prefix == "" {
+		declaration.Name = xml.Name{Local: "xmlns"}
+	}
+	return prefixElements(buffer.String(), prefix, []xml.Attr{declaration})
+}
+
+// the namespace bindings of an element written by writeTokens
+type xmlScope struct {
+	prefixes     map[string]string
+	preferred    map[string]string
+	defaultSpace string
+}
+
+// writeTokens writes decoded tokens, whose names hold namespaces instead of
+// prefixes, as XML. Namespaces are declared where first used, with their
+// original prefix if it was declared within the tokens, else the preferred,
+// the registered or a generated one.
+func writeTokens(buffer *bytes.Buffer, tokens []xml.Token, preferred map[string]string) {
+	scopes := []xmlScope{{prefixes: map[string]string{}, preferred: preferred}}
+	var names []string
+	for _, token := range tokens {
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := scopes[len(scopes)-1]
+			scope := xmlScope{prefixes: make(map[string]string, len(parent.prefixes)), preferred: parent.preferred, defaultSpace: parent.defaultSpace}
+			for namespace, prefix := range parent.prefixes {
+				scope.prefixes[namespace] = prefix
+			}
+
+			var attrs, declarations []xml.Attr
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					scope.prefixes[attr.Value] = attr.Name.Local
+					declarations = append(declarations, attr)
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope.defaultSpace = attr.Value
+					declarations = append(declarations, attr)
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+
+			name := scope.qualify(t.Name, false, &declarations)
+			buffer.WriteString("<" + name)
+			qualified := make([]string, len(attrs))
+			for i, attr := range attrs {
+				qualified[i] = scope.qualify(attr.Name, true, &declarations)
+			}
+			for _, attr := range declarations {
+				writeAttr(buffer, rawName(attr.Name), attr.Value)
+			}
+			for i, attr := range attrs {
+				writeAttr(buffer, qualified[i], attr.Value)
+			}
+			buffer.WriteString(">")
+			scopes = append(scopes, scope)
+			names = append(names, name)
+		case xml.EndElement:
+			buffer.WriteString("</" + names[len(names)-1] + ">")
+			scopes = scopes[:len(scopes)-1]
+			names = names[:len(names)-1]
+		case xml.CharData:
+			escapeText(buffer, t)
+		case xml.Comment:
+			buffer.WriteString("<!--" + string(t) + "-->")
+		}
+	}
+}
+
+// escapeText writes text with the characters that cannot appear in character
+// data escaped. Unlike xml.EscapeText, white space is written as is.
+func escapeText(buffer *bytes.Buffer, text []byte) {
+	buffer.WriteString(textEscaper.Replace(string(text)))
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func writeAttr(buffer *bytes.Buffer, name, value string) {
+	buffer.WriteString(" " + name + `="`)
+	xml.EscapeText(buffer, []byte(value))
+	buffer.WriteString(`"`)
+}
+
+// qualify returns the qualified name of name in the scope, adding the
+// declarations needed for it.
+func (scope *xmlScope) qualify(name xml.Name, attr bool, declarations *[]xml.Attr) string {
+	space := name.Space
+	switch {
+	case space == "":
+		if !attr && scope.defaultSpace != "" {
+			scope.defaultSpace = ""
+			*declarations = append(*declarations, xml.Attr{Name: xml.Name{Local: "xmlns"}})
+		}
+		return name.Local
+	case space == XML_NAMESPACE:
+		return "xml:" + name.Local
+	case !strings.Contains(space, ":"):
+		// A prefix the document never declared
+		return space + ":" + name.Local
+	case !attr && space == scope.defaultSpace:
+		return name.Local
+	case !attr && scope.defaultSpace == "" && (space == GPX11_NAMESPACE || space == GPX10_NAMESPACE):
+		// Unprefixed elements of the document namespace
+		return name.Local
+	}
+	if prefix, found := scope.prefixes[space]; found && prefix != "" {
+		return prefix + ":" + name.Local
+	}
+
+	prefix := scope.preferred[space]
+	if prefix == "" {
+		prefix = namespacePrefix(space)
+	}
+	for n := 1; prefix == "" || scope.prefixUsed(prefix); n++ {
+		prefix = "ns" + strconv.Itoa(n)
+	}
+	scope.prefixes[space] = prefix
+	*declarations = append(*declarations, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: space})
+	return prefix + ":" + name.Local
+}
+
+func (scope *xmlScope) prefixUsed(prefix string) bool {
+	for _, p := range scope.prefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+/*==========================================================*/
+// Extension
+
+// XML returns the element as XML, declaring the namespaces it uses.
+func (item Extension) XML() (string, error) {
+	if item.Value != nil {
+		return marshalExtension(item.Name, item.Value, item.prefixes)
+	}
+	var buffer bytes.Buffer
+	writeTokens(&buffer, item.raw, item.prefixes)
+	return buffer.String(), nil
+}
+
+/*==========================================================*/
+// Extensions
+
+// IsEmpty reports whether ext is nil or has no elements.
+func (ext *Extensions) IsEmpty() bool {
+	return ext == nil || len(ext.Items) == 0
+}
+
+// Get returns the value of the element local in namespace, or nil if there
+// is none or its type is not registered. The value is the one held by ext.
+func (ext *Extensions) Get(namespace, local string) interface{} {
+	if ext == nil {
+		return nil
+	}
+	name := xml.Name{Space: namespace, Local: local}
+	for _, item := range ext.Items {
+		if item.Name == name {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// Lookup returns the value of the element local in namespace as Get, or the
+// error decoding it if it is registered but malformed.
+func (ext *Extensions) Lookup(namespace, local string) (interface{}, error) {
+	if ext == nil {
+		return nil, nil
+	}
+	name := xml.Name{Space: namespace, Local: local}
+	for _, item := range ext.Items {
+		if item.Name == name {
+			return item.Value, item.err
+		}
+	}
+	return nil, nil
+}
+
+// Set replaces the element local in namespace with v, or adds it if there is
+// none. A nil v removes the element. Like append, it returns the extensions,
+// new ones if ext is nil and nil if no element is left, so that it is used as
+//
+//	wp.Extensions = wp.Extensions.Set(namespace, local, v)
+func (ext *Extensions) Set(namespace, local string, v interface{}) *Extensions {
+	if ext == nil {
+		ext = new(Extensions)
+	}
+	name := xml.Name{Space: namespace, Local: local}
+	for i, item := range ext.Items {
+		if item.Name != name {
+			continue
+		}
+		if v == nil {
+			ext.Items = append(ext.Items[:i], ext.Items[i+1:]...)
+		} else {
+			ext.Items[i] = Extension{Name: name, Value: v}
+		}
+		return ext.orNil()
+	}
+	if v != nil {
+		ext.Items = append(ext.Items, Extension{Name: name, Value: v})
+	}
+	return ext.orNil()
+}
+
+func (ext *Extensions) orNil() *Extensions {
+	if ext.IsEmpty() {
+		return nil
+	}
+	return ext
+}
+
+// copy returns a deep copy of ext, through XML so that registered values are
+// decoded anew.
+func (ext *Extensions) copy() *Extensions {
+	if ext == nil {
+		return nil
+	}
+	copied := new(Extensions)
+	content, err := ext.XML()
+	if err != nil || copied.AddXML(content) != nil {
+		// The elements were read or written before, not expected
+		copied.Items = append([]Extension(nil), ext.Items...)
+	}
+	return copied
+}
+
+// AddXML appends the elements of content, which must declare the namespaces
+// it uses.
+func (ext *Extensions) AddXML(content string) error {
+	parsed := new(Extensions)
+	if err := xml.Unmarshal([]byte("<extensions>"+content+"</extensions>"), parsed); err != nil {
+		return err
+	}
+	ext.Items = append(ext.Items, parsed.Items...)
+	return nil
+}
+
+// XML returns the content of the <extensions> element.
+func (ext *Extensions) XML() (string, error) {
+	if ext == nil {
+		return "", nil
+	}
+	var buffer bytes.Buffer
+	for _, item := range ext.Items {
+		content, err := item.XML()
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString(content)
+	}
+	return buffer.String(), nil
+}
+
+func (ext *Extensions) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	ext.Items = nil
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			item, err := decodeExtension(d, t)
+			if err != nil {
+				return err
+			}
+			ext.Items = append(ext.Items, item)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// MarshalXML writes nothing when there are no elements.
+func (ext *Extensions) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	content, err := ext.XML()
+	if err != nil || content == "" {
+		return err
+	}
+	return e.EncodeElement(struct {
+		Content string `xml:",innerxml"`
+	}{content}, start)
+}
+
+// prefixElements rewrites marshalled XML with prefix on the unprefixed
+// elements, except below a default namespace declaration, and attrs on the
+// root element.
+func prefixElements(content, prefix string, attrs []xml.Attr) (string, error) {
+	var buffer bytes.Buffer
+	d := xml.NewDecoder(strings.NewReader(content))
+	var names []string
+	prefixed := []bool{prefix != ""}
+	for {
+		token, err := d.RawToken()
+		if err == io.EOF {
+			return buffer.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			add := prefixed[len(prefixed)-1]
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					add = false
+				}
+			}
+			if len(names) == 0 {
+				t.Attr = append(append([]xml.Attr{}, attrs...), t.Attr...)
+			}
+			name := rawName(t.Name)
+			if add && t.Name.Space == "" {
+				name = prefix + ":" + t.Name.Local
+			}
+			buffer.WriteString("<" + name)
+			for _, attr := range t.Attr {
+				writeAttr(&buffer, rawName(attr.Name), attr.Value)
+			}
+			buffer.WriteString(">")
+			names = append(names, name)
+			prefixed = append(prefixed, add)
+		case xml.EndElement:
+			buffer.WriteString("</" + names[len(names)-1] + ">")
+			names = names[:len(names)-1]
+			prefixed = prefixed[:len(prefixed)-1]
+		case xml.CharData:
+			escapeText(&buffer, t)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+type testRunExtension struct {
+	Effort int    `xml:"effort"`
+	Note   string `xml:"note,omitempty"`
+}
+
+func init() {
+	RegisterExtension("urn:test:run", "run", "run", testRunExtension{})
+}
+
+var extensionsDocument = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:run="urn:test:run" xmlns:acme="urn:acme" version="1.1" creator="test">
+  <metadata>
+    <extensions><run:run><run:effort>1</run:effort></run:run></extensions>
+  </metadata>
+  <wpt lat="1" lon="2">
+    <extensions><run:run><run:effort>2</run:effort></run:run><acme:color acme:shade="dark">red<acme:b/></acme:color></extensions>
+  </wpt>
+  <rte>
+    <extensions><run:run><run:effort>3</run:effort></run:run></extensions>
+  </rte>
+  <trk>
+    <extensions><run:run><run:effort>4</run:effort></run:run></extensions>
+    <trkseg>
+      <trkpt lat="1" lon="2"><extensions><speed>3.5</speed></extensions></trkpt>
+      <extensions><run:run><run:effort>5</run:effort></run:run></extensions>
+    </trkseg>
+  </trk>
+  <extensions><run:run><run:effort>6</run:effort><run:note>done</run:note></run:run></extensions>
+</gpx>`)
+
+func runEffort(ext *Extensions) int {
+	run, ok := ext.Get("urn:test:run", "run").(*testRunExtension)
+	if !ok {
+		return -1
+	}
+	return run.Effort
+}
+
+func TestRegisteredExtensions(t *testing.T) {
+	gpx, err := ParseWithContent(extensionsDocument)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, 1, runEffort(gpx.Metadata.Extensions))
+	assert.Equal(t, 2, runEffort(gpx.Waypoints[0].Extensions))
+	assert.Equal(t, 3, runEffort(gpx.Routes[0].Extensions))
+	assert.Equal(t, 4, runEffort(gpx.Tracks[0].Extensions))
+	assert.Equal(t, 5, runEffort(gpx.Tracks[0].Segments[0].Extensions))
+	assert.Equal(t, 6, runEffort(gpx.Extensions))
+	assert.Equal(t, &testRunExtension{Effort: 6, Note: "done"}, gpx.Extensions.Get("urn:test:run", "run"))
+	assert.Equal(t, nil, gpx.Extensions.Get("urn:test:run", "other"))
+	assert.Equal(t, nil, (*Extensions)(nil).Get("urn:test:run", "run"))
+}
+
+func TestUnknownExtensionsRoundTrip(t *testing.T) {
+	gpx, err := ParseWithContent(extensionsDocument)
+	assert.Equal(t, nil, err)
+
+	item := gpx.Waypoints[0].Extensions.Items[1]
+	assert.Equal(t, "urn:acme", item.Name.Space)
+	assert.Equal(t, nil, item.Value)
+	content, err := item.XML()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `<ns1:color xmlns:ns1="urn:acme" ns1:shade="dark">red<ns1:b></ns1:b></ns1:color>`, content)
+
+	// Unprefixed elements of the GPX namespace stay unprefixed.
+	content, _ = gpx.Tracks[0].Segments[0].Waypoints[0].Extensions.XML()
+	assert.Equal(t, `<speed>3.5</speed>`, content)
+
+	reparsed, err := ParseWithContent(gpx.ToXML())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 6, runEffort(reparsed.Extensions))
+	assert.Equal(t, 5, runEffort(reparsed.Tracks[0].Segments[0].Extensions))
+	content2, _ := reparsed.Waypoints[0].Extensions.XML()
+	content, _ = gpx.Waypoints[0].Extensions.XML()
+	assert.Equal(t, content, content2)
+	assert.Equal(t, true, strings.Contains(string(gpx.ToXML()), `<run:run xmlns:run="urn:test:run"><run:effort>6</run:effort><run:note>done</run:note></run:run>`))
+}
+
+func TestIndentedExtensionsRoundTrip(t *testing.T) {
+	document := []byte("<gpx xmlns=\"http://www.topografix.com/GPX/1/1\" version=\"1.1\" creator=\"test\"><wpt lat=\"1\" lon=\"2\"><extensions>\n" +
+		"\t\t<acme:a xmlns:acme=\"urn:acme\" acme:note='say \"hi\"'>\n\t\t\t<acme:b>1 &amp; 2 &lt; 3</acme:b>\n\t\t</acme:a>\n\t</extensions></wpt></gpx>")
+	gpx, err := ParseWithContent(document)
+	assert.Equal(t, nil, err)
+
+	content, err := gpx.Waypoints[0].Extensions.XML()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "<acme:a xmlns:acme=\"urn:acme\" acme:note=\"say &#34;hi&#34;\">\n\t\t\t<acme:b>1 &amp; 2 &lt; 3</acme:b>\n\t\t</acme:a>", content)
+
+	reparsed, err := ParseWithContent(gpx.ToXML())
+	assert.Equal(t, nil, err)
+	content2, _ := reparsed.Waypoints[0].Extensions.XML()
+	assert.Equal(t, content, content2)
+}
+
+func TestSetExtension(t *testing.T) {
+	ext := new(Extensions)
+	assert.Equal(t, nil, ext.AddXML(`<a xmlns="urn:a">1</a>`))
+	ext.Set("urn:test:run", "run", &testRunExtension{Effort: 7})
+	ext.Set("urn:test:run", "run", &testRunExtension{Effort: 8})
+	assert.Equal(t, 2, len(ext.Items))
+	assert.Equal(t, 8, runEffort(ext))
+
+	content, err := ext.XML()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `<a xmlns="urn:a">1</a><run:run xmlns:run="urn:test:run"><run:effort>8</run:effort></run:run>`, content)
+
+	ext.Set("urn:test:run", "run", nil)
+	ext.Set("urn:a", "a", nil)
+	assert.Equal(t, true, ext.IsEmpty())
+	assert.Equal(t, false, strings.Contains(string(toXMLCompact(&Wpt{Extensions: ext})), "extensions"))
+
+	var wp Wpt
+	wp.Extensions = wp.Extensions.Set("urn:test:run", "run", &testRunExtension{Effort: 9})
+	assert.Equal(t, 9, runEffort(wp.Extensions))
+	wp.Extensions = wp.Extensions.Set("urn:test:run", "run", nil)
+	assert.Equal(t, (*Extensions)(nil), wp.Extensions)
+}
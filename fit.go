@@ -0,0 +1,678 @@
+package gpxgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The namespace of the extensions holding FIT data without a GPX or Garmin
+// equivalent
+const (
+	FIT_NAMESPACE = "https://github.com/pikeszfish/gpxgo/fit/v1"
+	FIT_PREFIX    = "fit"
+)
+
+// FIT global message numbers
+const (
+	fitSession          = 18
+	fitLap              = 19
+	fitRecord           = 20
+	fitCourse           = 31
+	fitCoursePoint      = 32
+	fitFieldDescription = 206
+)
+
+// Field number of the timestamp of every message
+const fitTimestamp = 253
+
+// FIT timestamps are seconds since 1989-12-31T00:00:00Z.
+const fitEpoch = 631065600
+
+// FITDeveloperFields are the FIT developer fields of a point or track: data
+// added by a Connect IQ app, e.g. the running power of a foot pod.
+type FITDeveloperFields struct {
+	Fields []FITDeveloperField `xml:"field"`
+}
+
+// FITDeveloperField is a developer field, with its value as text: the
+// number, scaled, or the values of an array separated by spaces.
+type FITDeveloperField struct {
+	Name  string `xml:"name,attr"`
+	Units string `xml:"units,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type fitBaseType struct {
+	size        int
+	signed      bool
+	float       bool
+	zeroInvalid bool
+}
+
+// a field of a definition message
+type fitFieldDefinition struct {
+	num      byte
+	size     byte
+	baseType byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	order     binary.ByteOrder
+	fields    []fitFieldDefinition
+	devFields []fitFieldDefinition // baseType is the developer data index
+}
+
+// a field_description message
+type fitDevFieldDescription struct {
+	name     string
+	units    string
+	baseType byte
+	scale    float64
+	offset   float64
+}
+
+// fitMessage is a data message, with its valid field values: float64,
+// []float64 for arrays, string or []byte.
+type fitMessage struct {
+	global    uint16
+	fields    map[byte]interface{}
+	devFields []FITDeveloperField
+}
+
+// fitDecoder reads the records of a FIT file, computing their CRC.
+type fitDecoder struct {
+	r             *bufio.Reader
+	crc           uint16
+	remaining     uint32
+	definitions   map[byte]*fitDefinition
+	descriptions  map[[2]byte]fitDevFieldDescription
+	lastTimestamp uint32
+}
+
+// fitActivity builds a Gpx from the messages of a FIT file.
+type fitActivity struct {
+	gpx        *Gpx
+	seg        Trkseg
+	segments   []Trkseg
+	courseName string
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// By base type number, the low 5 bits of the base type
+var fitBaseTypes = []fitBaseType{
+	{size: 1},                    // enum
+	{size: 1, signed: true},      // sint8
+	{size: 1},                    // uint8
+	{size: 2, signed: true},      // sint16
+	{size: 2},                    // uint16
+	{size: 4, signed: true},      // sint32
+	{size: 4},                    // uint32
+	{size: 1},                    // string
+	{size: 4, float: true},       // float32
+	{size: 8, float: true},       // float64
+	{size: 1, zeroInvalid: true}, // uint8z
+	{size: 2, zeroInvalid: true}, // uint16z
+	{size: 4, zeroInvalid: true}, // uint32z
+	{size: 1},                    // byte
+	{size: 8, signed: true},      // sint64
+	{size: 8},                    // uint64
+	{size: 8, zeroInvalid: true}, // uint64z
+}
+
+var fitSports = []string{"generic", "running", "cycling", "transition", "fitness_equipment",
+	"swimming", "basketball", "soccer", "tennis", "american_football", "training", "walking",
+	"cross_country_skiing", "alpine_skiing", "snowboarding", "rowing", "mountaineering", "hiking",
+	"multisport", "paddling"}
+
+var fitCoursePointTypes = []string{"generic", "summit", "valley", "water", "food", "danger", "left",
+	"right", "straight", "first_aid", "fourth_category", "third_category", "second_category",
+	"first_category", "hors_category", "sprint", "left_fork", "right_fork", "middle_fork",
+	"slight_left", "sharp_left", "slight_right", "sharp_right", "u_turn", "segment_start", "segment_end"}
+
+func init() {
+	RegisterExtension(FIT_NAMESPACE, "DeveloperFields", FIT_PREFIX, FITDeveloperFields{})
+}
+
+/*==========================================================*/
+// Static
+
+// ParseFITWithReader decodes a Garmin/ANT FIT file, e.g. recorded by a watch.
+// Each session is read as a track with a segment per lap, typed with its
+// sport (or, without sessions, as one track, named after the course of a
+// course file). Records are its points, with heart rate, cadence and
+// temperature in their TrackPointExtension, speed and power in their
+// ActivityExtension, the distance as DistanceMeters and developer fields as
+// FITDeveloperFields. Records without a position are skipped. Course points
+// are read as waypoints. Chained FIT files are read one after the other.
+func ParseFITWithReader(r io.Reader) (*Gpx, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(r)
+	activity := &fitActivity{gpx: NewGpx()}
+	for {
+		if _, err := buffered.Peek(1); err == io.EOF {
+			break
+		}
+		if err := newFITDecoder(buffered).decode(activity.add); err != nil {
+			return nil, err
+		}
+	}
+	return activity.finish(), nil
+}
+
+func ParseFITWithContent(content []byte) (*Gpx, error) {
+	return ParseFITWithReader(bytes.NewReader(content))
+}
+
+func newFITDecoder(r *bufio.Reader) *fitDecoder {
+	return &fitDecoder{
+		r:            r,
+		definitions:  map[byte]*fitDefinition{},
+		descriptions: map[[2]byte]fitDevFieldDescription{},
+	}
+}
+
+func fitCRC(crc uint16, b byte) uint16 {
+	tmp := fitCRCTable[crc&0xF]
+	crc = (crc >> 4) & 0x0FFF
+	crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+	tmp = fitCRCTable[crc&0xF]
+	crc = (crc >> 4) & 0x0FFF
+	return crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+}
+
+func fitCRCOf(content []byte) uint16 {
+	var crc uint16
+	for _, b := range content {
+		crc = fitCRC(crc, b)
+	}
+	return crc
+}
+
+func fitTime(timestamp uint32) time.Time {
+	return time.Unix(fitEpoch+int64(timestamp), 0).UTC()
+}
+
+// fitDegrees converts semicircles to degrees.
+func fitDegrees(semicircles float64) float64 {
+	return semicircles * 180 / (1 << 31)
+}
+
+func fitName(names []string, value float64) string {
+	if i := int(value); i >= 0 && i < len(names) {
+		return names[i]
+	}
+	return strconv.Itoa(int(value))
+}
+
+// fitDecodeValue returns the value of a field, or false if it is invalid.
+func fitDecodeValue(content []byte, baseType byte, order binary.ByteOrder) (interface{}, bool) {
+	num := int(baseType & 0x1F)
+	if num >= len(fitBaseTypes) {
+		return nil, false
+	}
+	switch num {
+	case 7:
+		if i := bytes.IndexByte(content, 0); i >= 0 {
+			content = content[:i]
+		}
+		return string(content), len(content) > 0
+	case 13:
+		for _, b := range content {
+			if b != 0xFF {
+				return append([]byte(nil), content...), true
+			}
+		}
+		return nil, false
+	}
+
+	bt := fitBaseTypes[num]
+	if len(content) < bt.size || len(content)%bt.size != 0 {
+		return nil, false
+	}
+	var values []float64
+	valid := false
+	for i := 0; i < len(content); i += bt.size {
+		value, ok := bt.decode(content[i:i+bt.size], order)
+		valid = valid || ok
+		values = append(values, value)
+	}
+	if !valid {
+		return nil, false
+	}
+	if len(values) == 1 {
+		return values[0], true
+	}
+	return values, true
+}
+
+/*==========================================================*/
+// fitBaseType
+
+// decode returns the value of content, of the size of bt, or false if it is
+// the invalid value.
+func (bt fitBaseType) decode(content []byte, order binary.ByteOrder) (float64, bool) {
+	var u uint64
+	switch bt.size {
+	case 1:
+		u = uint64(content[0])
+	case 2:
+		u = uint64(order.Uint16(content))
+	case 4:
+		u = uint64(order.Uint32(content))
+	case 8:
+		u = order.Uint64(content)
+	}
+	bits := uint(bt.size * 8)
+	allOnes := uint64(1)<<bits - 1
+	if bits == 64 {
+		allOnes = math.MaxUint64
+	}
+
+	switch {
+	case bt.zeroInvalid:
+		return float64(u), u != 0
+	case bt.float && bt.size == 4:
+		return float64(math.Float32frombits(uint32(u))), u != allOnes
+	case bt.float:
+		return math.Float64frombits(u), u != allOnes
+	case bt.signed:
+		// Sign extension
+		shift := 64 - bits
+		return float64(int64(u<<shift) >> shift), u != allOnes>>1
+	}
+	return float64(u), u != allOnes
+}
+
+/*==========================================================*/
+// fitDecoder
+func (dec *fitDecoder) read(n int) ([]byte, error) {
+	if uint32(n) > dec.remaining {
+		return nil, errors.New("gpxgo: FIT message beyond the data size")
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(dec.r, content); err != nil {
+		return nil, fmt.Errorf("gpxgo: FIT: %w", io.ErrUnexpectedEOF)
+	}
+	for _, b := range content {
+		dec.crc = fitCRC(dec.crc, b)
+	}
+	dec.remaining -= uint32(n)
+	return content, nil
+}
+
+// decode reads a FIT file, passing its data messages to handle.
+func (dec *fitDecoder) decode(handle func(*fitMessage)) error {
+	start, err := dec.r.Peek(12)
+	if err != nil || start[0] < 12 || string(start[8:12]) != ".FIT" {
+		return errors.New("gpxgo: not a FIT file")
+	}
+	header := make([]byte, start[0])
+	if _, err := io.ReadFull(dec.r, header); err != nil {
+		return fmt.Errorf("gpxgo: FIT header: %w", io.ErrUnexpectedEOF)
+	}
+	// The header CRC, optional, is not checked, the file CRC covers it.
+	dec.crc = fitCRCOf(header)
+	dec.remaining = binary.LittleEndian.Uint32(header[4:8])
+
+	for dec.remaining > 0 {
+		msg, err := dec.readMessage()
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			handle(msg)
+		}
+	}
+
+	crc := dec.crc
+	trailer := make([]byte, 2)
+	if _, err := io.ReadFull(dec.r, trailer); err != nil {
+		return fmt.Errorf("gpxgo: FIT CRC: %w", io.ErrUnexpectedEOF)
+	}
+	if binary.LittleEndian.Uint16(trailer) != crc {
+		return errors.New("gpxgo: FIT CRC mismatch")
+	}
+	return nil
+}
+
+// readMessage reads a definition message, returning nil, or a data message.
+func (dec *fitDecoder) readMessage() (*fitMessage, error) {
+	header, err := dec.read(1)
+	if err != nil {
+		return nil, err
+	}
+	h := header[0]
+
+	if h&0x80 != 0 {
+		// Compressed timestamp header: 5 bits of time offset
+		offset := uint32(h & 0x1F)
+		timestamp := dec.lastTimestamp&^0x1F + offset
+		if offset < dec.lastTimestamp&0x1F {
+			timestamp += 0x20
+		}
+		msg, err := dec.readData((h >> 5) & 0x03)
+		if err != nil {
+			return nil, err
+		}
+		msg.fields[fitTimestamp] = float64(timestamp)
+		dec.lastTimestamp = timestamp
+		return msg, nil
+	}
+	if h&0x40 != 0 {
+		return nil, dec.readDefinition(h&0x0F, h&0x20 != 0)
+	}
+	msg, err := dec.readData(h & 0x0F)
+	if err != nil {
+		return nil, err
+	}
+	if timestamp, ok := msg.fields[fitTimestamp].(float64); ok {
+		dec.lastTimestamp = uint32(timestamp)
+	}
+	return msg, nil
+}
+
+func (dec *fitDecoder) readDefinition(local byte, developer bool) error {
+	content, err := dec.read(5)
+	if err != nil {
+		return err
+	}
+	def := &fitDefinition{order: binary.LittleEndian}
+	if content[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(content[2:4])
+
+	fields, err := dec.readFieldDefinitions(int(content[4]))
+	if err != nil {
+		return err
+	}
+	def.fields = fields
+	if developer {
+		count, err := dec.read(1)
+		if err != nil {
+			return err
+		}
+		if def.devFields, err = dec.readFieldDefinitions(int(count[0])); err != nil {
+			return err
+		}
+	}
+	dec.definitions[local] = def
+	return nil
+}
+
+func (dec *fitDecoder) readFieldDefinitions(n int) ([]fitFieldDefinition, error) {
+	content, err := dec.read(3 * n)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]fitFieldDefinition, n)
+	for i := range fields {
+		fields[i] = fitFieldDefinition{num: content[3*i], size: content[3*i+1], baseType: content[3*i+2]}
+	}
+	return fields, nil
+}
+
+func (dec *fitDecoder) readData(local byte) (*fitMessage, error) {
+	def := dec.definitions[local]
+	if def == nil {
+		return nil, fmt.Errorf("gpxgo: FIT data message of undefined local type %d", local)
+	}
+	msg := &fitMessage{global: def.global, fields: map[byte]interface{}{}}
+	for _, field := range def.fields {
+		content, err := dec.read(int(field.size))
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := fitDecodeValue(content, field.baseType, def.order); ok {
+			msg.fields[field.num] = value
+		}
+	}
+	for _, field := range def.devFields {
+		content, err := dec.read(int(field.size))
+		if err != nil {
+			return nil, err
+		}
+		description, found := dec.descriptions[[2]byte{field.baseType, field.num}]
+		if !found {
+			continue
+		}
+		if value, ok := fitDecodeValue(content, description.baseType, def.order); ok {
+			msg.devFields = append(msg.devFields, description.field(value))
+		}
+	}
+
+	if msg.global == fitFieldDescription {
+		dec.describe(msg)
+	}
+	return msg, nil
+}
+
+// describe registers the developer field described by msg.
+func (dec *fitDecoder) describe(msg *fitMessage) {
+	index, ok1 := msg.fields[0].(float64)
+	num, ok2 := msg.fields[1].(float64)
+	baseType, ok3 := msg.fields[2].(float64)
+	if !ok1 || !ok2 || !ok3 {
+		return
+	}
+	description := fitDevFieldDescription{baseType: byte(baseType), scale: 1}
+	description.name, _ = msg.fields[3].(string)
+	description.units, _ = msg.fields[8].(string)
+	if scale, ok := msg.fields[6].(float64); ok && scale != 0 {
+		description.scale = scale
+	}
+	description.offset, _ = msg.fields[7].(float64)
+	dec.descriptions[[2]byte{byte(index), byte(num)}] = description
+}
+
+/*==========================================================*/
+// fitDevFieldDescription
+func (description fitDevFieldDescription) field(value interface{}) FITDeveloperField {
+	field := FITDeveloperField{Name: description.name, Units: description.units}
+	format := func(v float64) string {
+		return strconv.FormatFloat(v/description.scale-description.offset, 'f', -1, 64)
+	}
+	switch v := value.(type) {
+	case float64:
+		field.Value = format(v)
+	case []float64:
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = format(v[i])
+		}
+		field.Value = strings.Join(values, " ")
+	case string:
+		field.Value = v
+	case []byte:
+		field.Value = fmt.Sprintf("%x", v)
+	}
+	return field
+}
+
+/*==========================================================*/
+// fitMessage
+func (msg *fitMessage) float(num byte) (float64, bool) {
+	value, ok := msg.fields[num].(float64)
+	return value, ok
+}
+
+func (msg *fitMessage) time(num byte) NullableTime {
+	if value, ok := msg.float(num); ok {
+		return NewNullableTime(fitTime(uint32(value)))
+	}
+	return NullableTime{}
+}
+
+// scaled returns the value of the field num as value/scale - offset.
+func (msg *fitMessage) scaled(num byte, scale, offset float64) NullableFloat64 {
+	if value, ok := msg.float(num); ok {
+		return NewNullableFloat64(value/scale - offset)
+	}
+	return NullableFloat64{}
+}
+
+func (msg *fitMessage) int(num byte) NullableInt {
+	if value, ok := msg.float(num); ok {
+		return NewNullableInt(int(value))
+	}
+	return NullableInt{}
+}
+
+// position returns the position of the fields lat and lon, in semicircles.
+func (msg *fitMessage) position(lat, lon byte) (float64, float64, bool) {
+	latitude, ok1 := msg.float(lat)
+	longitude, ok2 := msg.float(lon)
+	return fitDegrees(latitude), fitDegrees(longitude), ok1 && ok2
+}
+
+// firstValid returns the first valid of fields.
+func firstValid(fields ...NullableFloat64) NullableFloat64 {
+	for _, field := range fields {
+		if field.Valid {
+			return field
+		}
+	}
+	return NullableFloat64{}
+}
+
+func (msg *fitMessage) wpt() (Wpt, bool) {
+	lat, lon, ok := msg.position(0, 1)
+	if !ok {
+		return Wpt{}, false
+	}
+	wp := Wpt{
+		Lat:  lat,
+		Lon:  lon,
+		Ele:  firstValid(msg.scaled(78, 5, 500), msg.scaled(2, 5, 500)),
+		Time: msg.time(fitTimestamp),
+	}
+
+	tpx := TrackPointExtension{HR: msg.int(3), Cad: msg.int(4), ATemp: msg.scaled(13, 1, 0)}
+	if tpx.HR.Valid || tpx.Cad.Valid || tpx.ATemp.Valid {
+		wp.SetTrackPointExtension(&tpx)
+	}
+	ax := ActivityExtension{Speed: firstValid(msg.scaled(73, 1000, 0), msg.scaled(6, 1000, 0)), Watts: msg.int(7)}
+	if ax.Speed.Valid || ax.Watts.Valid {
+		wp.SetActivityExtension(&ax)
+	}
+	wp.SetDistanceMeters(msg.scaled(5, 100, 0))
+	if len(msg.devFields) > 0 {
+		wp.SetFITDeveloperFields(&FITDeveloperFields{Fields: msg.devFields})
+	}
+	return wp, true
+}
+
+/*==========================================================*/
+// fitActivity
+func (a *fitActivity) add(msg *fitMessage) {
+	switch msg.global {
+	case fitRecord:
+		if wp, ok := msg.wpt(); ok {
+			a.seg.Waypoints = append(a.seg.Waypoints, wp)
+		}
+	case fitLap:
+		a.endLap()
+	case fitSession:
+		a.endLap()
+		trk := Trk{Segments: a.segments}
+		if sport, ok := msg.float(5); ok {
+			trk.Type = fitName(fitSports, sport)
+		}
+		if len(msg.devFields) > 0 {
+			trk.SetFITDeveloperFields(&FITDeveloperFields{Fields: msg.devFields})
+		}
+		a.gpx.Tracks = append(a.gpx.Tracks, trk)
+		a.segments = nil
+	case fitCourse:
+		a.courseName, _ = msg.fields[5].(string)
+	case fitCoursePoint:
+		lat, lon, ok := msg.position(2, 3)
+		if !ok {
+			return
+		}
+		wp := Wpt{Lat: lat, Lon: lon, Time: msg.time(1)}
+		wp.Name, _ = msg.fields[6].(string)
+		if typ, ok := msg.float(5); ok {
+			wp.Type = fitName(fitCoursePointTypes, typ)
+		}
+		a.gpx.Waypoints = append(a.gpx.Waypoints, wp)
+	}
+}
+
+func (a *fitActivity) endLap() {
+	if len(a.seg.Waypoints) > 0 {
+		a.segments = append(a.segments, a.seg)
+	}
+	a.seg = Trkseg{}
+}
+
+func (a *fitActivity) finish() *Gpx {
+	a.endLap()
+	if len(a.segments) > 0 {
+		a.gpx.Tracks = append(a.gpx.Tracks, Trk{Segments: a.segments})
+	}
+	if a.courseName != "" {
+		for i := range a.gpx.Tracks {
+			a.gpx.Tracks[i].Name = a.courseName
+		}
+	}
+	return a.gpx
+}
+
+/*==========================================================*/
+// Tracks
+
+// FITDeveloperFields returns the developer fields of the session of the
+// track, or nil if it has none.
+func (t *Trk) FITDeveloperFields() (*FITDeveloperFields, error) {
+	v, err := t.Extensions.Lookup(FIT_NAMESPACE, "DeveloperFields")
+	ext, _ := v.(*FITDeveloperFields)
+	return ext, err
+}
+
+// SetFITDeveloperFields replaces the developer fields of the track, or
+// removes them if ext is nil.
+func (t *Trk) SetFITDeveloperFields(ext *FITDeveloperFields) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	t.Extensions = t.Extensions.Set(FIT_NAMESPACE, "DeveloperFields", v)
+}
+
+/*==========================================================*/
+// Wpt
+
+// FITDeveloperFields returns the developer fields of the record of the
+// point, or nil if it has none.
+func (wp *Wpt) FITDeveloperFields() (*FITDeveloperFields, error) {
+	v, err := wp.Extensions.Lookup(FIT_NAMESPACE, "DeveloperFields")
+	ext, _ := v.(*FITDeveloperFields)
+	return ext, err
+}
+
+// SetFITDeveloperFields replaces the developer fields of the point, or
+// removes them if ext is nil.
+func (wp *Wpt) SetFITDeveloperFields(ext *FITDeveloperFields) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	wp.Extensions = wp.Extensions.Set(FIT_NAMESPACE, "DeveloperFields", v)
+}
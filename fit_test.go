@@ -0,0 +1,157 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+// fitBuilder writes the records of a FIT file.
+type fitBuilder struct {
+	bytes.Buffer
+}
+
+func (b *fitBuilder) define(local byte, global uint16, order binary.ByteOrder, fields [][3]byte, devFields [][3]byte) {
+	header := 0x40 | local
+	if devFields != nil {
+		header |= 0x20
+	}
+	b.WriteByte(header)
+	b.WriteByte(0)
+	if order == binary.BigEndian {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	binary.Write(b, order, global)
+	b.WriteByte(byte(len(fields)))
+	for _, field := range fields {
+		b.Write(field[:])
+	}
+	if devFields != nil {
+		b.WriteByte(byte(len(devFields)))
+		for _, field := range devFields {
+			b.Write(field[:])
+		}
+	}
+}
+
+func (b *fitBuilder) data(header byte, order binary.ByteOrder, values ...interface{}) {
+	b.WriteByte(header)
+	for _, value := range values {
+		binary.Write(b, order, value)
+	}
+}
+
+// file returns the records as a FIT file with a 14 bytes header.
+func (b *fitBuilder) file() []byte {
+	header := []byte{14, 0x10, 0x2d, 0x08, 0, 0, 0, 0, '.', 'F', 'I', 'T'}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(b.Len()))
+	header = append(header, 0, 0)
+	binary.LittleEndian.PutUint16(header[12:], fitCRCOf(header[:12]))
+	content := append(header, b.Bytes()...)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, fitCRCOf(content))
+	return append(content, crc...)
+}
+
+func fitString(s string, size int) []byte {
+	content := make([]byte, size)
+	copy(content, s)
+	return content
+}
+
+func fitSemicircles(degrees float64) int32 {
+	return int32(degrees * (1 << 31) / 180)
+}
+
+func fitSample() []byte {
+	le, be := binary.LittleEndian, binary.BigEndian
+	var b fitBuilder
+	// field_description: index, number, base type, name, scale, units
+	b.define(0, fitFieldDescription, le, [][3]byte{{0, 1, 0x02}, {1, 1, 0x02}, {2, 1, 0x02}, {3, 16, 0x07}, {6, 1, 0x02}, {8, 8, 0x07}}, nil)
+	b.data(0, le, uint8(0), uint8(0), uint8(0x84), fitString("Form Power", 16), uint8(1), fitString("watts", 8))
+	// record: timestamp, lat, lon, enhanced altitude, heart rate, power, distance and a developer field
+	b.define(1, fitRecord, le, [][3]byte{{253, 4, 0x86}, {0, 4, 0x85}, {1, 4, 0x85}, {78, 4, 0x86}, {3, 1, 0x02}, {7, 2, 0x84}, {5, 4, 0x86}}, [][3]byte{{0, 2, 0}})
+	b.data(1, le, uint32(1000), fitSemicircles(45), fitSemicircles(7.5), uint32(8500), uint8(150), uint16(250), uint32(12345), uint16(260))
+	// Without position
+	b.data(1, le, uint32(1001), int32(0x7FFFFFFF), int32(0x7FFFFFFF), uint32(8500), uint8(151), uint16(0xFFFF), uint32(12400), uint16(0xFFFF))
+	// record, big endian, with a compressed timestamp: lat, lon, altitude, speed
+	b.define(2, fitRecord, be, [][3]byte{{0, 4, 0x85}, {1, 4, 0x85}, {2, 2, 0x84}, {6, 2, 0x84}}, nil)
+	b.data(0x80|2<<5|10, be, fitSemicircles(45.001), fitSemicircles(7.501), uint16(8505), uint16(4200))
+	// lap
+	b.define(3, fitLap, le, [][3]byte{{253, 4, 0x86}}, nil)
+	b.data(3, le, uint32(1003))
+	b.data(0x80|2<<5|5, be, fitSemicircles(45.002), fitSemicircles(7.502), uint16(0xFFFF), uint16(0xFFFF))
+	b.data(3, le, uint32(1040))
+	// session: timestamp, sport
+	b.define(4, fitSession, le, [][3]byte{{253, 4, 0x86}, {5, 1, 0x00}}, nil)
+	b.data(4, le, uint32(1041), uint8(2))
+	// course point: timestamp, lat, lon, type, name
+	b.define(5, fitCoursePoint, le, [][3]byte{{1, 4, 0x86}, {2, 4, 0x85}, {3, 4, 0x85}, {5, 1, 0x00}, {6, 8, 0x07}}, nil)
+	b.data(5, le, uint32(1020), fitSemicircles(45.0015), fitSemicircles(7.5015), uint8(6), fitString("Turn", 8))
+	return b.file()
+}
+
+func TestParseFIT(t *testing.T) {
+	gpx, err := ParseFITWithContent(fitSample())
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, 1, len(gpx.Tracks))
+	trk := gpx.Tracks[0]
+	assert.Equal(t, "cycling", trk.Type)
+	assert.Equal(t, 2, len(trk.Segments))
+	assert.Equal(t, 2, len(trk.Segments[0].Waypoints))
+	assert.Equal(t, 1, len(trk.Segments[1].Waypoints))
+
+	wp := trk.Segments[0].Waypoints[0]
+	assert.Equal(t, 45.0, wp.Lat)
+	assert.Equal(t, true, wp.Lon > 7.4999999 && wp.Lon < 7.5)
+	assert.Equal(t, NewNullableFloat64(1200), wp.Ele)
+	assert.Equal(t, "1989-12-31T00:16:40Z", wp.Time.String())
+	tpx, _ := wp.TrackPointExtension()
+	assert.Equal(t, &TrackPointExtension{HR: NewNullableInt(150)}, tpx)
+	ax, _ := wp.ActivityExtension()
+	assert.Equal(t, &ActivityExtension{Watts: NewNullableInt(250)}, ax)
+	assert.Equal(t, NewNullableFloat64(123.45), wp.DistanceMeters())
+	fields, _ := wp.FITDeveloperFields()
+	assert.Equal(t, &FITDeveloperFields{Fields: []FITDeveloperField{{Name: "Form Power", Units: "watts", Value: "260"}}}, fields)
+
+	// Compressed timestamp, big endian
+	wp = trk.Segments[0].Waypoints[1]
+	assert.Equal(t, "1989-12-31T00:16:42Z", wp.Time.String())
+	assert.Equal(t, NewNullableFloat64(1201), wp.Ele)
+	ax, _ = wp.ActivityExtension()
+	assert.Equal(t, NewNullableFloat64(4.2), ax.Speed)
+	// The time offset rolls over.
+	wp = trk.Segments[1].Waypoints[0]
+	assert.Equal(t, "1989-12-31T00:17:09Z", wp.Time.String())
+	assert.Equal(t, false, wp.Ele.Valid)
+	assert.Equal(t, (*Extensions)(nil), wp.Extensions)
+
+	assert.Equal(t, 1, len(gpx.Waypoints))
+	assert.Equal(t, "Turn", gpx.Waypoints[0].Name)
+	assert.Equal(t, "left", gpx.Waypoints[0].Type)
+}
+
+func TestParseFITChained(t *testing.T) {
+	sample := fitSample()
+	gpx, err := ParseFITWithContent(append(append([]byte(nil), sample...), sample...))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(gpx.Tracks))
+	assert.Equal(t, 2, len(gpx.Waypoints))
+}
+
+func TestParseFITErrors(t *testing.T) {
+	sample := fitSample()
+	sample[len(sample)-1] ^= 0xFF
+	_, err := ParseFITWithContent(sample)
+	assert.Equal(t, "gpxgo: FIT CRC mismatch", err.Error())
+
+	_, err = ParseFITWithContent(fitSample()[:100])
+	assert.NotEqual(t, nil, err)
+
+	_, err = ParseFITWithContent([]byte("<gpx></gpx>"))
+	assert.Equal(t, "gpxgo: not a FIT file", err.Error())
+}
@@ -0,0 +1,468 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FIT global message numbers written only
+const (
+	fitFileID          = 0
+	fitEvent           = 21
+	fitActivityMessage = 34
+)
+
+// FIT base types written
+const (
+	fitBaseEnum   = 0x00
+	fitBaseSint8  = 0x01
+	fitBaseUint8  = 0x02
+	fitBaseUint16 = 0x84
+	fitBaseSint32 = 0x85
+	fitBaseUint32 = 0x86
+	fitBaseString = 0x07
+)
+
+const (
+	fitProtocolVersion = 0x10
+	fitProfileVersion  = 2093
+	// Manufacturer "development"
+	fitManufacturer = 255
+	// Speed, in m/s, of the points without time in courses
+	fitDefaultSpeed = 5.0
+	// Bytes of the longest string written, with its NUL terminator making
+	// the largest field size
+	fitMaxString = 254
+)
+
+// a field of a message to write, value being of a fixed size type or []byte
+type fitField struct {
+	num      byte
+	baseType byte
+	value    interface{}
+}
+
+// fitEncoder writes the records of a FIT file, defining each global message
+// with its own local type, again when its fields change.
+type fitEncoder struct {
+	buffer      bytes.Buffer
+	locals      map[uint16]byte
+	definitions map[byte]string
+}
+
+/*==========================================================*/
+// Static
+func newFITEncoder(fileType byte, created time.Time) *fitEncoder {
+	enc := &fitEncoder{locals: map[uint16]byte{}, definitions: map[byte]string{}}
+	enc.write(fitFileID,
+		fitField{0, fitBaseEnum, fileType},
+		fitField{1, fitBaseUint16, uint16(fitManufacturer)},
+		fitField{2, fitBaseUint16, uint16(0)},
+		fitTimeField(4, created))
+	return enc
+}
+
+func fitTimestampOf(t time.Time) uint32 {
+	return uint32(t.Unix() - fitEpoch)
+}
+
+func fitTimeField(num byte, t time.Time) fitField {
+	return fitField{num, fitBaseUint32, fitTimestampOf(t)}
+}
+
+func fitSemicirclesField(num byte, degrees float64) fitField {
+	return fitField{num, fitBaseSint32, int32(math.Round(degrees * (1 << 31) / 180))}
+}
+
+// fitStringField returns the field of s, cut to fitMaxString bytes on a
+// character boundary.
+func fitStringField(num byte, s string) fitField {
+	if len(s) > fitMaxString {
+		cut := fitMaxString
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return fitField{num, fitBaseString, append([]byte(s), 0)}
+}
+
+// fitScaledField returns the field of a uint16 or uint32 value, written as
+// (value + offset) * scale.
+func fitScaledField(num, baseType byte, value NullableFloat64, scale, offset float64) fitField {
+	scaled := math.Round((value.Float64 + offset) * scale)
+	if baseType == fitBaseUint16 {
+		if !value.Valid || scaled < 0 || scaled >= math.MaxUint16 {
+			return fitField{num, baseType, uint16(math.MaxUint16)}
+		}
+		return fitField{num, baseType, uint16(scaled)}
+	}
+	if !value.Valid || scaled < 0 || scaled >= math.MaxUint32 {
+		return fitField{num, baseType, uint32(math.MaxUint32)}
+	}
+	return fitField{num, baseType, uint32(scaled)}
+}
+
+func fitUint8Field(num byte, value NullableInt) fitField {
+	if !value.Valid || value.Int < 0 || value.Int >= math.MaxUint8 {
+		return fitField{num, fitBaseUint8, uint8(math.MaxUint8)}
+	}
+	return fitField{num, fitBaseUint8, uint8(value.Int)}
+}
+
+func fitSint8Field(num byte, value NullableFloat64) fitField {
+	if !value.Valid || value.Float64 < math.MinInt8 || value.Float64 >= math.MaxInt8 {
+		return fitField{num, fitBaseSint8, int8(math.MaxInt8)}
+	}
+	return fitField{num, fitBaseSint8, int8(math.Round(value.Float64))}
+}
+
+// fitIndex returns the index of name in names, ignoring case, or else 0.
+func fitIndex(names []string, name string) byte {
+	for i := range names {
+		if strings.EqualFold(names[i], name) {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+func fitSportOf(typ string) byte {
+	if strings.EqualFold(typ, TCX_BIKING) {
+		return fitIndex(fitSports, "cycling")
+	}
+	return fitIndex(fitSports, typ)
+}
+
+// fitTimeline returns the timestamps and distances written for points, after
+// a point at last and distance. Points keep their time unless it is before
+// the previous one. Points without time are given one at fitDefaultSpeed from
+// the previous point or, before the first timed point, back from it, though
+// not before last. Points with a DistanceMeters keep it, the others get the
+// distance travelled since.
+func fitTimeline(points Waypoints, last time.Time, distance float64) ([]time.Time, []float64) {
+	times := make([]time.Time, len(points))
+	distances := make([]float64, len(points))
+	steps := make([]float64, len(points))
+	first := -1
+	for i := range points {
+		if i > 0 {
+			steps[i] = points[i].Length2D(&points[i-1])
+		}
+		distance += steps[i]
+		if stored := points[i].DistanceMeters(); stored.Valid {
+			distance = stored.Float64
+		}
+		distances[i] = distance
+		if first < 0 && points[i].Time.Valid && !points[i].Time.Time.Before(last) {
+			first = i
+		}
+	}
+
+	if first > 0 {
+		times[first] = points[first].Time.Time
+		for i := first - 1; i >= 0; i-- {
+			times[i] = times[i+1].Add(-fitDuration(steps[i+1]))
+		}
+	}
+	for i := range points {
+		t := last.Add(fitDuration(steps[i]))
+		switch {
+		case i < first:
+			t = times[i]
+		case points[i].Time.Valid && !points[i].Time.Time.Before(last):
+			t = points[i].Time.Time
+		}
+		if t.Before(last) {
+			t = last
+		}
+		times[i], last = t, t
+	}
+	return times, distances
+}
+
+// fitDuration returns the time to cover meters at fitDefaultSpeed.
+func fitDuration(meters float64) time.Duration {
+	return time.Duration(meters / fitDefaultSpeed * float64(time.Second))
+}
+
+// fitStart returns the time written for the first of points, or now if none
+// has a time.
+func fitStart(points Waypoints) time.Time {
+	if timeBoundsOf(points) == nil {
+		return time.Now().Truncate(time.Second)
+	}
+	times, _ := fitTimeline(points, time.Time{}, 0)
+	return times[0]
+}
+
+/*==========================================================*/
+// fitEncoder
+func (enc *fitEncoder) write(global uint16, fields ...fitField) {
+	local, found := enc.locals[global]
+	if !found {
+		local = byte(len(enc.locals) % 16)
+		enc.locals[global] = local
+	}
+
+	var definition bytes.Buffer
+	definition.Write([]byte{0x40 | local, 0, 0})
+	binary.Write(&definition, binary.LittleEndian, global)
+	definition.WriteByte(byte(len(fields)))
+	for _, field := range fields {
+		definition.Write([]byte{field.num, byte(binary.Size(field.value)), field.baseType})
+	}
+	if enc.definitions[local] != definition.String() {
+		enc.definitions[local] = definition.String()
+		enc.buffer.Write(definition.Bytes())
+	}
+
+	enc.buffer.WriteByte(local)
+	for _, field := range fields {
+		binary.Write(&enc.buffer, binary.LittleEndian, field.value)
+	}
+}
+
+func (enc *fitEncoder) writeEvent(t time.Time, eventType byte) {
+	// Event timer, of group 0
+	enc.write(fitEvent, fitTimeField(fitTimestamp, t), fitField{0, fitBaseEnum, uint8(0)}, fitField{1, fitBaseEnum, eventType}, fitField{4, fitBaseUint8, uint8(0)})
+}
+
+// writeRecords writes the records of points, at their times and distances.
+func (enc *fitEncoder) writeRecords(points Waypoints, times []time.Time, distances []float64) {
+	for i := range points {
+		enc.write(fitRecord, points[i].fitRecordFields(times[i], distances[i])...)
+	}
+}
+
+// writeLap writes a lap of points with their times and distances, from the
+// distance before the first.
+func (enc *fitEncoder) writeLap(points Waypoints, times []time.Time, distances []float64, distance float64, activity bool) {
+	fields := []fitField{fitTimeField(fitTimestamp, times[len(times)-1]), fitTimeField(2, times[0])}
+	first, last := &points[0], &points[len(points)-1]
+	fields = append(fields,
+		fitSemicirclesField(3, first.Lat), fitSemicirclesField(4, first.Lon),
+		fitSemicirclesField(5, last.Lat), fitSemicirclesField(6, last.Lon))
+	elapsed := NewNullableFloat64(times[len(times)-1].Sub(times[0]).Seconds())
+	fields = append(fields,
+		fitScaledField(7, fitBaseUint32, elapsed, 1000, 0),
+		fitScaledField(8, fitBaseUint32, elapsed, 1000, 0),
+		fitScaledField(9, fitBaseUint32, NewNullableFloat64(distances[len(distances)-1]-distance), 100, 0))
+	if activity {
+		// Event lap, stop
+		fields = append(fields, fitField{0, fitBaseEnum, uint8(9)}, fitField{1, fitBaseEnum, uint8(1)})
+	}
+	enc.write(fitLap, fields...)
+}
+
+// writeTo writes the FIT file, with a 14 bytes header, to w.
+func (enc *fitEncoder) writeTo(w io.Writer) error {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = fitProtocolVersion
+	binary.LittleEndian.PutUint16(header[2:4], fitProfileVersion)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(enc.buffer.Len()))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], fitCRCOf(header[:12]))
+
+	crc := fitCRCOf(header)
+	for _, b := range enc.buffer.Bytes() {
+		crc = fitCRC(crc, b)
+	}
+	trailer := make([]byte, 2)
+	binary.LittleEndian.PutUint16(trailer, crc)
+
+	for _, content := range [][]byte{header, enc.buffer.Bytes(), trailer} {
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*==========================================================*/
+// Gpx
+
+// WriteFITActivity writes the tracks as a FIT activity file: a session per
+// track, of the sport named by its type, a lap per segment and a record per
+// point, with the sensor data of its extensions. Developer fields are not
+// written. Points without time are given one, see WriteFITCourse. Tracks
+// without points are left out.
+func (g *Gpx) WriteFITActivity(w io.Writer) error {
+	var points Waypoints
+	for i := range g.Tracks {
+		for j := range g.Tracks[i].Segments {
+			points = append(points, g.Tracks[i].Segments[j].Waypoints...)
+		}
+	}
+	start := fitStart(points)
+	enc := newFITEncoder(4, start)
+	enc.writeEvent(start, 0)
+
+	last := start
+	var (
+		timerTime float64
+		sessions  int
+		lapIndex  int
+	)
+	for i := range g.Tracks {
+		trk := &g.Tracks[i]
+		var (
+			sessionStart time.Time
+			distance     float64
+			laps         int
+		)
+		for j := range trk.Segments {
+			points := trk.Segments[j].Waypoints
+			if len(points) == 0 {
+				continue
+			}
+			times, distances := fitTimeline(points, last, distance)
+			if laps == 0 {
+				sessionStart = times[0]
+			}
+			enc.writeRecords(points, times, distances)
+			enc.writeLap(points, times, distances, distance, true)
+			last, distance = times[len(times)-1], distances[len(distances)-1]
+			laps++
+		}
+		if laps == 0 {
+			// Nothing recorded
+			continue
+		}
+
+		elapsed := NewNullableFloat64(last.Sub(sessionStart).Seconds())
+		timerTime += elapsed.Float64
+		enc.write(fitSession,
+			fitTimeField(fitTimestamp, last),
+			fitTimeField(2, sessionStart),
+			fitField{5, fitBaseEnum, fitSportOf(trk.Type)},
+			fitField{6, fitBaseEnum, uint8(0)},
+			fitScaledField(7, fitBaseUint32, elapsed, 1000, 0),
+			fitScaledField(8, fitBaseUint32, elapsed, 1000, 0),
+			fitScaledField(9, fitBaseUint32, NewNullableFloat64(distance), 100, 0),
+			fitField{25, fitBaseUint16, uint16(lapIndex)},
+			fitField{26, fitBaseUint16, uint16(laps)},
+			// Event session, stop
+			fitField{0, fitBaseEnum, uint8(8)},
+			fitField{1, fitBaseEnum, uint8(1)})
+		lapIndex += laps
+		sessions++
+	}
+
+	// Stop all
+	enc.writeEvent(last, 4)
+	enc.write(fitActivityMessage,
+		fitTimeField(fitTimestamp, last),
+		fitScaledField(0, fitBaseUint32, NewNullableFloat64(timerTime), 1000, 0),
+		fitField{1, fitBaseUint16, uint16(sessions)},
+		// Manual, event activity, stop
+		fitField{2, fitBaseEnum, uint8(0)},
+		fitField{3, fitBaseEnum, uint8(26)},
+		fitField{4, fitBaseEnum, uint8(1)})
+	return enc.writeTo(w)
+}
+
+// writeFITCourse writes points as a course named name, with the named
+// waypoints as course points.
+func writeFITCourse(w io.Writer, name, typ string, points Waypoints, waypoints Waypoints) error {
+	if len(points) == 0 {
+		return fmt.Errorf("gpxgo: course %q without points", name)
+	}
+	start := fitStart(points)
+	times, distances := fitTimeline(points, start, 0)
+
+	enc := newFITEncoder(6, start)
+	enc.write(fitCourse, fitStringField(5, name), fitField{4, fitBaseEnum, fitSportOf(typ)})
+	enc.writeLap(points, times, distances, 0, false)
+	enc.writeEvent(start, 0)
+	enc.writeRecords(points, times, distances)
+	for i := range waypoints {
+		wp := &waypoints[i]
+		if wp.Name == "" {
+			continue
+		}
+		// At the nearest point of the course
+		nearest := 0
+		for j := range points {
+			if wp.Length2D(&points[j]) < wp.Length2D(&points[nearest]) {
+				nearest = j
+			}
+		}
+		enc.write(fitCoursePoint,
+			fitTimeField(1, times[nearest]),
+			fitSemicirclesField(2, wp.Lat),
+			fitSemicirclesField(3, wp.Lon),
+			fitScaledField(4, fitBaseUint32, NewNullableFloat64(distances[nearest]), 100, 0),
+			fitField{5, fitBaseEnum, fitIndex(fitCoursePointTypes, wp.Type)},
+			fitStringField(6, wp.Name))
+	}
+	// Stop, disable all
+	enc.writeEvent(times[len(times)-1], 9)
+	return enc.writeTo(w)
+}
+
+/*==========================================================*/
+// Routes
+
+// WriteFITCourse writes the route as a FIT course file, for head units. The
+// named waypoints are course points, at the nearest point of the route, typed
+// by their type if it is a FIT course point type (e.g. "left", "summit").
+// Points without time are given one at 5 m/s from the previous, or back from
+// the first timed point for the points before it. Names longer than 254
+// bytes are cut.
+func (r *Rte) WriteFITCourse(w io.Writer, waypoints Waypoints) error {
+	return writeFITCourse(w, r.Name, r.Type, r.Waypoints, waypoints)
+}
+
+/*==========================================================*/
+// Tracks
+
+// WriteFITCourse writes the points of the track as a FIT course file, see
+// Rte.WriteFITCourse.
+func (t *Trk) WriteFITCourse(w io.Writer, waypoints Waypoints) error {
+	var points Waypoints
+	for i := range t.Segments {
+		points = append(points, t.Segments[i].Waypoints...)
+	}
+	return writeFITCourse(w, t.Name, t.Type, points, waypoints)
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) fitRecordFields(t time.Time, distance float64) []fitField {
+	var tpx TrackPointExtension
+	if ext, _ := wp.TrackPointExtension(); ext != nil {
+		tpx = *ext
+	}
+	var ax ActivityExtension
+	if ext, _ := wp.ActivityExtension(); ext != nil {
+		ax = *ext
+	}
+	var power NullableFloat64
+	if ax.Watts.Valid {
+		power.SetValue(float64(ax.Watts.Int))
+	}
+	speed := ax.Speed
+	if !speed.Valid {
+		speed = tpx.Speed
+	}
+	return []fitField{
+		fitTimeField(fitTimestamp, t),
+		fitSemicirclesField(0, wp.Lat),
+		fitSemicirclesField(1, wp.Lon),
+		fitScaledField(2, fitBaseUint16, wp.Ele, 5, 500),
+		fitUint8Field(3, tpx.HR),
+		fitUint8Field(4, tpx.Cad),
+		fitScaledField(5, fitBaseUint32, NewNullableFloat64(distance), 100, 0),
+		fitScaledField(6, fitBaseUint16, speed, 1000, 0),
+		fitScaledField(7, fitBaseUint16, power, 1, 0),
+		fitSint8Field(13, tpx.ATemp),
+	}
+}
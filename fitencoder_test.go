@@ -0,0 +1,156 @@
+package gpxgo
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteFITActivity(t *testing.T) {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	first := Wpt{Lat: 46, Lon: 13.5, Ele: NewNullableFloat64(120), Time: NewNullableTime(start)}
+	first.SetTrackPointExtension(&TrackPointExtension{HR: NewNullableInt(98), Cad: NewNullableInt(80), ATemp: NewNullableFloat64(21)})
+	first.SetActivityExtension(&ActivityExtension{Speed: NewNullableFloat64(4.2), Watts: NewNullableInt(180)})
+	second := Wpt{Lat: 46.001, Lon: 13.5, Time: NewNullableTime(start.Add(10 * time.Second))}
+	third := Wpt{Lat: 46.002, Lon: 13.5, Time: NewNullableTime(start.Add(20 * time.Second))}
+	third.SetDistanceMeters(NewNullableFloat64(250))
+
+	gpx := NewGpx()
+	gpx.Tracks = []Trk{
+		{Type: TCX_BIKING, Segments: []Trkseg{{Waypoints: Waypoints{first, second}}, {Waypoints: Waypoints{third}}}},
+		{Type: "running", Segments: []Trkseg{{Waypoints: Waypoints{{Lat: 1, Lon: 2, Time: NewNullableTime(start.Add(time.Hour))}}}}},
+	}
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteFITActivity(&buffer))
+
+	reparsed, err := ParseFITWithContent(buffer.Bytes())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(reparsed.Tracks))
+	trk := reparsed.Tracks[0]
+	assert.Equal(t, "cycling", trk.Type)
+	assert.Equal(t, 2, len(trk.Segments))
+	assert.Equal(t, "running", reparsed.Tracks[1].Type)
+
+	wp := trk.Segments[0].Waypoints[0]
+	assert.Equal(t, true, wp.Lat > 45.9999999 && wp.Lat < 46.0000001)
+	assert.Equal(t, NewNullableFloat64(120), wp.Ele)
+	assert.Equal(t, "2016-06-29T10:00:00Z", wp.Time.String())
+	tpx, _ := wp.TrackPointExtension()
+	assert.Equal(t, &TrackPointExtension{HR: NewNullableInt(98), Cad: NewNullableInt(80), ATemp: NewNullableFloat64(21)}, tpx)
+	ax, _ := wp.ActivityExtension()
+	assert.Equal(t, &ActivityExtension{Speed: NewNullableFloat64(4.2), Watts: NewNullableInt(180)}, ax)
+	assert.Equal(t, NewNullableFloat64(0), wp.DistanceMeters())
+
+	// Missing values are invalid, distances computed or kept
+	wp = trk.Segments[0].Waypoints[1]
+	assert.Equal(t, false, wp.Ele.Valid)
+	tpx, _ = wp.TrackPointExtension()
+	assert.Equal(t, (*TrackPointExtension)(nil), tpx)
+	assert.Equal(t, true, wp.DistanceMeters().Float64 > 111 && wp.DistanceMeters().Float64 < 112)
+	assert.Equal(t, NewNullableFloat64(250), trk.Segments[1].Waypoints[0].DistanceMeters())
+}
+
+func TestWriteFITCourse(t *testing.T) {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	rte := Rte{Name: "Loop", Type: "cycling", Waypoints: Waypoints{
+		{Lat: 46, Lon: 13.5, Time: NewNullableTime(start)},
+		// Without time, at 5 m/s
+		{Lat: 46.001, Lon: 13.5},
+		{Lat: 46.002, Lon: 13.5},
+	}}
+	waypoints := Waypoints{
+		{Lat: 46.0011, Lon: 13.5001, Name: "Left turn", Type: "Left"},
+		{Lat: 46.002, Lon: 13.5, Name: "Top", Type: "summit"},
+		{Lat: 46, Lon: 13.5},
+	}
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, rte.WriteFITCourse(&buffer, waypoints))
+
+	reparsed, err := ParseFITWithContent(buffer.Bytes())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(reparsed.Tracks))
+	assert.Equal(t, "Loop", reparsed.Tracks[0].Name)
+	points := reparsed.Tracks[0].Segments[0].Waypoints
+	assert.Equal(t, 3, len(points))
+	assert.Equal(t, "2016-06-29T10:00:22Z", points[1].Time.String())
+
+	// The unnamed waypoint is skipped, the others are at the nearest point.
+	assert.Equal(t, 2, len(reparsed.Waypoints))
+	assert.Equal(t, "Left turn", reparsed.Waypoints[0].Name)
+	assert.Equal(t, "left", reparsed.Waypoints[0].Type)
+	assert.Equal(t, points[1].Time, reparsed.Waypoints[0].Time)
+	assert.Equal(t, "Top", reparsed.Waypoints[1].Name)
+	assert.Equal(t, "summit", reparsed.Waypoints[1].Type)
+
+	trk := Trk{Name: "Loop", Type: "cycling", Segments: []Trkseg{{Waypoints: rte.Waypoints[:2]}, {Waypoints: rte.Waypoints[2:]}}}
+	var trkBuffer bytes.Buffer
+	assert.Equal(t, nil, trk.WriteFITCourse(&trkBuffer, waypoints))
+	assert.Equal(t, buffer.Bytes(), trkBuffer.Bytes())
+
+	err = (&Rte{Name: "Empty"}).WriteFITCourse(&buffer, nil)
+	assert.Equal(t, `gpxgo: course "Empty" without points`, err.Error())
+}
+
+func TestWriteFITActivitySessions(t *testing.T) {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	point := func(minutes int) Wpt {
+		return Wpt{Lat: 46, Lon: 13.5, Time: NewNullableTime(start.Add(time.Duration(minutes) * time.Minute))}
+	}
+	gpx := NewGpx()
+	gpx.Tracks = []Trk{
+		{Segments: []Trkseg{{Waypoints: Waypoints{point(0)}}, {Waypoints: Waypoints{point(1)}}}},
+		{Segments: []Trkseg{{}}},
+		{Segments: []Trkseg{{Waypoints: Waypoints{point(2)}}}},
+	}
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteFITActivity(&buffer))
+
+	// Sessions point at their own laps; the track without points has none.
+	var firstLaps, sessions []interface{}
+	err := newFITDecoder(bufio.NewReader(&buffer)).decode(func(msg *fitMessage) {
+		switch msg.global {
+		case fitSession:
+			firstLaps = append(firstLaps, msg.fields[25])
+		case fitActivityMessage:
+			sessions = append(sessions, msg.fields[1])
+		}
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []interface{}{0.0, 2.0}, firstLaps)
+	assert.Equal(t, []interface{}{2.0}, sessions)
+}
+
+func TestWriteFITCourseTimeline(t *testing.T) {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	rte := Rte{Name: strings.Repeat("x", 300), Waypoints: Waypoints{
+		// Back from the next point, at 5 m/s
+		{Lat: 46, Lon: 13.5},
+		{Lat: 46.001, Lon: 13.5, Time: NewNullableTime(start)},
+		{Lat: 46.002, Lon: 13.5, Time: NewNullableTime(start)},
+	}}
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, rte.WriteFITCourse(&buffer, nil))
+
+	reparsed, err := ParseFITWithContent(buffer.Bytes())
+	assert.Equal(t, nil, err)
+	trk := reparsed.Tracks[0]
+	assert.Equal(t, strings.Repeat("x", 254), trk.Name)
+	points := trk.Segments[0].Waypoints
+	assert.Equal(t, "2016-06-29T09:59:37Z", points[0].Time.String())
+	assert.Equal(t, "2016-06-29T10:00:00Z", points[1].Time.String())
+	assert.Equal(t, "2016-06-29T10:00:00Z", points[2].Time.String())
+
+	// Names are cut between characters.
+	rte.Name = strings.Repeat("é", 200)
+	buffer.Reset()
+	assert.Equal(t, nil, rte.WriteFITCourse(&buffer, Waypoints{{Lat: 46, Lon: 13.5, Name: rte.Name}}))
+	reparsed, err = ParseFITWithContent(buffer.Bytes())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, strings.Repeat("é", 127), reparsed.Tracks[0].Name)
+	assert.Equal(t, true, utf8.ValidString(reparsed.Waypoints[0].Name))
+	assert.Equal(t, 254, len(reparsed.Waypoints[0].Name))
+}
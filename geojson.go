@@ -0,0 +1,300 @@
+package gpxgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// A GeoJSON position: longitude, latitude and, if known, elevation
+type geoJSONPosition []float64
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   *geoJSONGeometry  `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+// geoJSONGeometry holds, according to Type, a geoJSONPosition (Point),
+// []geoJSONPosition (LineString) or [][]geoJSONPosition (MultiLineString).
+// Coordinates of other geometries are not decoded.
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Name string `json:"name,omitempty"`
+	Cmt  string `json:"cmt,omitempty"`
+	Desc string `json:"desc,omitempty"`
+	Type string `json:"type,omitempty"`
+	Sym  string `json:"sym,omitempty"`
+	// Of a Point
+	Time string   `json:"time,omitempty"`
+	Ele  *float64 `json:"ele,omitempty"`
+	// Times of the positions of a line, null when unknown: []*string for a
+	// LineString, [][]*string for a MultiLineString
+	CoordTimes interface{} `json:"coordTimes,omitempty"`
+}
+
+/*==========================================================*/
+// Static
+
+// ParseGeoJSONWithContent rebuilds a document from a GeoJSON FeatureCollection
+// as written by ToGeoJSON: Points are read as waypoints, LineStrings as routes
+// and MultiLineStrings as tracks. Features without geometry are skipped,
+// other geometries are an error, and so are positions without longitude and
+// latitude.
+func ParseGeoJSONWithContent(content []byte) (*Gpx, error) {
+	var collection geoJSONCollection
+	if err := json.Unmarshal(content, &collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("gpxgo: GeoJSON %q is not a FeatureCollection", collection.Type)
+	}
+
+	gpx := NewGpx()
+	for i, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		props := feature.Properties
+		times := flattenCoordTimes(props.CoordTimes, nil)
+		switch coordinates := feature.Geometry.Coordinates.(type) {
+		case geoJSONPosition:
+			wp, err := props.wpt(coordinates)
+			if err != nil {
+				return nil, fmt.Errorf("gpxgo: GeoJSON feature %d: %w", i, err)
+			}
+			if props.Time != "" {
+				t, err := ParseTime(props.Time)
+				if err != nil {
+					return nil, fmt.Errorf("gpxgo: GeoJSON feature %d: %w", i, err)
+				}
+				wp.Time.SetValue(t)
+			}
+			if wp.Ele.IsNull() && props.Ele != nil {
+				wp.Ele.SetValue(*props.Ele)
+			}
+			gpx.Waypoints = append(gpx.Waypoints, wp)
+		case []geoJSONPosition:
+			points, err := geoJSONPoints(coordinates, times)
+			if err != nil {
+				return nil, fmt.Errorf("gpxgo: GeoJSON feature %d: %w", i, err)
+			}
+			gpx.Routes = append(gpx.Routes, Rte{Name: props.Name, Cmt: props.Cmt, Desc: props.Desc, Type: props.Type, Waypoints: points})
+		case [][]geoJSONPosition:
+			trk := Trk{Name: props.Name, Cmt: props.Cmt, Desc: props.Desc, Type: props.Type}
+			for _, line := range coordinates {
+				points, err := geoJSONPoints(line, times)
+				if err != nil {
+					return nil, fmt.Errorf("gpxgo: GeoJSON feature %d: %w", i, err)
+				}
+				if len(times) > len(line) {
+					times = times[len(line):]
+				} else {
+					times = nil
+				}
+				trk.Segments = append(trk.Segments, Trkseg{Waypoints: points})
+			}
+			gpx.Tracks = append(gpx.Tracks, trk)
+		default:
+			return nil, fmt.Errorf("gpxgo: GeoJSON feature %d: unsupported geometry %q", i, feature.Geometry.Type)
+		}
+	}
+	return gpx, nil
+}
+
+func ParseGeoJSONWithReader(r io.Reader) (*Gpx, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGeoJSONWithContent(content)
+}
+
+// flattenCoordTimes appends the times, nested in arrays as decoded, to times.
+func flattenCoordTimes(v interface{}, times []interface{}) []interface{} {
+	if values, ok := v.([]interface{}); ok {
+		for _, value := range values {
+			if _, nested := value.([]interface{}); nested {
+				times = flattenCoordTimes(value, times)
+			} else {
+				times = append(times, value)
+			}
+		}
+	}
+	return times
+}
+
+// geoJSONPoints returns the points of a line, with the first times.
+func geoJSONPoints(line []geoJSONPosition, times []interface{}) (Waypoints, error) {
+	points := make(Waypoints, len(line))
+	for i, position := range line {
+		wp, err := geoJSONProperties{}.wpt(position)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = wp
+		if i >= len(times) {
+			continue
+		}
+		if value, ok := times[i].(string); ok && value != "" {
+			t, err := ParseTime(value)
+			if err != nil {
+				return nil, err
+			}
+			points[i].Time.SetValue(t)
+		}
+	}
+	return points, nil
+}
+
+func geoJSONLine(points Waypoints) ([]geoJSONPosition, []*string, bool) {
+	line := make([]geoJSONPosition, len(points))
+	times := make([]*string, len(points))
+	timed := false
+	for i := range points {
+		line[i] = points[i].geoJSONPosition()
+		if !points[i].Time.IsNull() {
+			value := FormatTime(points[i].Time.Time)
+			times[i] = &value
+			timed = true
+		}
+	}
+	return line, times, timed
+}
+
+/*==========================================================*/
+// Gpx
+
+// ToGeoJSON converts the document to a GeoJSON FeatureCollection: waypoints
+// are Points, routes LineStrings and tracks MultiLineStrings with one line per
+// segment. Positions are [lon, lat, ele], without ele when unknown. Names,
+// descriptions, types, times and elevations are properties, the times of
+// lines in coordTimes.
+func (g *Gpx) ToGeoJSON() ([]byte, error) {
+	collection := geoJSONCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	add := func(kind string, coordinates interface{}, props geoJSONProperties) {
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   &geoJSONGeometry{Type: kind, Coordinates: coordinates},
+			Properties: props,
+		})
+	}
+
+	for _, wp := range g.Waypoints {
+		props := geoJSONProperties{Name: wp.Name, Cmt: wp.Cmt, Desc: wp.Desc, Type: wp.Type, Sym: wp.Sym}
+		if !wp.Time.IsNull() {
+			props.Time = FormatTime(wp.Time.Time)
+		}
+		if !wp.Ele.IsNull() {
+			ele := wp.Ele.Float64
+			props.Ele = &ele
+		}
+		add("Point", wp.geoJSONPosition(), props)
+	}
+	for _, rte := range g.Routes {
+		props := geoJSONProperties{Name: rte.Name, Cmt: rte.Cmt, Desc: rte.Desc, Type: rte.Type}
+		line, times, timed := geoJSONLine(rte.Waypoints)
+		if timed {
+			props.CoordTimes = times
+		}
+		add("LineString", line, props)
+	}
+	for _, trk := range g.Tracks {
+		props := geoJSONProperties{Name: trk.Name, Cmt: trk.Cmt, Desc: trk.Desc, Type: trk.Type}
+		lines := make([][]geoJSONPosition, len(trk.Segments))
+		segmentTimes := make([][]*string, len(trk.Segments))
+		anyTimed := false
+		for i := range trk.Segments {
+			var timed bool
+			lines[i], segmentTimes[i], timed = geoJSONLine(trk.Segments[i].Waypoints)
+			anyTimed = anyTimed || timed
+		}
+		if anyTimed {
+			props.CoordTimes = segmentTimes
+		}
+		add("MultiLineString", lines, props)
+	}
+	return json.Marshal(collection)
+}
+
+// WriteGeoJSON writes the document to w as ToGeoJSON.
+func (g *Gpx) WriteGeoJSON(w io.Writer) error {
+	content, err := g.ToGeoJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+/*==========================================================*/
+// geoJSONGeometry
+func (geometry *geoJSONGeometry) UnmarshalJSON(content []byte) error {
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return err
+	}
+	geometry.Type = raw.Type
+	geometry.Coordinates = nil
+
+	switch raw.Type {
+	case "Point":
+		var position geoJSONPosition
+		if err := json.Unmarshal(raw.Coordinates, &position); err != nil {
+			return err
+		}
+		geometry.Coordinates = position
+	case "LineString":
+		var line []geoJSONPosition
+		if err := json.Unmarshal(raw.Coordinates, &line); err != nil {
+			return err
+		}
+		geometry.Coordinates = line
+	case "MultiLineString":
+		var lines [][]geoJSONPosition
+		if err := json.Unmarshal(raw.Coordinates, &lines); err != nil {
+			return err
+		}
+		geometry.Coordinates = lines
+	}
+	return nil
+}
+
+/*==========================================================*/
+// geoJSONProperties
+
+// wpt returns a waypoint at position, with the properties of a Point. A
+// position without both longitude and latitude is an error.
+func (props geoJSONProperties) wpt(position geoJSONPosition) (Wpt, error) {
+	if len(position) < 2 {
+		return Wpt{}, fmt.Errorf("position %v without longitude and latitude", []float64(position))
+	}
+	wp := Wpt{Name: props.Name, Cmt: props.Cmt, Desc: props.Desc, Type: props.Type, Sym: props.Sym}
+	wp.Lon, wp.Lat = position[0], position[1]
+	if len(position) >= 3 {
+		wp.Ele.SetValue(position[2])
+	}
+	return wp, nil
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) geoJSONPosition() geoJSONPosition {
+	if wp.Ele.IsNull() {
+		return geoJSONPosition{wp.Lon, wp.Lat}
+	}
+	return geoJSONPosition{wp.Lon, wp.Lat, wp.Ele.Float64}
+}
@@ -0,0 +1,74 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func geoJSONSample() *Gpx {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	gpx := NewGpx()
+	gpx.Waypoints = Waypoints{{Lat: 45.5, Lon: 7.25, Ele: NewNullableFloat64(1200), Time: NewNullableTime(start), Name: "Hut", Desc: "Closed in winter", Sym: "Lodge"}}
+	gpx.Routes = []Rte{{Name: "Ascent", Waypoints: Waypoints{{Lat: 45, Lon: 7}, {Lat: 45.1, Lon: 7.1}}}}
+	gpx.Tracks = []Trk{{Name: "Walk", Type: "hiking", Segments: []Trkseg{
+		{Waypoints: Waypoints{{Lat: 1, Lon: 2, Ele: NewNullableFloat64(3), Time: NewNullableTime(start)}, {Lat: 1.5, Lon: 2.5}}},
+		{Waypoints: Waypoints{{Lat: 4, Lon: 5, Time: NewNullableTime(start.Add(time.Hour))}}},
+	}}}
+	return gpx
+}
+
+func TestToGeoJSON(t *testing.T) {
+	content, err := geoJSONSample().ToGeoJSON()
+	assert.Equal(t, nil, err)
+	for _, expected := range []string{
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[7.25,45.5,1200]},"properties":{"name":"Hut","desc":"Closed in winter","sym":"Lodge","time":"2016-06-29T10:00:00Z","ele":1200}}`,
+		`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[7,45],[7.1,45.1]]},"properties":{"name":"Ascent"}}`,
+		`{"type":"Feature","geometry":{"type":"MultiLineString","coordinates":[[[2,1,3],[2.5,1.5]],[[5,4]]]},"properties":{"name":"Walk","type":"hiking","coordTimes":[["2016-06-29T10:00:00Z",null],["2016-06-29T11:00:00Z"]]}}`,
+	} {
+		assert.Equal(t, true, strings.Contains(string(content), expected), expected)
+	}
+	assert.Equal(t, true, strings.HasPrefix(string(content), `{"type":"FeatureCollection","features":[`))
+
+	empty, err := NewGpx().ToGeoJSON()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"type":"FeatureCollection","features":[]}`, string(empty))
+}
+
+func TestGeoJSONRoundTrip(t *testing.T) {
+	gpx := geoJSONSample()
+	content, err := gpx.ToGeoJSON()
+	assert.Equal(t, nil, err)
+
+	parsed, err := ParseGeoJSONWithContent(content)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, gpx.Waypoints, parsed.Waypoints)
+	assert.Equal(t, gpx.Routes[0].Waypoints, parsed.Routes[0].Waypoints)
+	assert.Equal(t, "Ascent", parsed.Routes[0].Name)
+	assert.Equal(t, gpx.Tracks[0].Segments, parsed.Tracks[0].Segments)
+	assert.Equal(t, "hiking", parsed.Tracks[0].Type)
+}
+
+func TestParseGeoJSONErrors(t *testing.T) {
+	_, err := ParseGeoJSONWithReader(strings.NewReader(`{"type":"Feature","geometry":null,"properties":{}}`))
+	assert.Equal(t, `gpxgo: GeoJSON "Feature" is not a FeatureCollection`, err.Error())
+
+	_, err = ParseGeoJSONWithContent([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":null,"properties":{}},
+		{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,1],[0,0]]]},"properties":{}}]}`))
+	assert.Equal(t, `gpxgo: GeoJSON feature 1: unsupported geometry "Polygon"`, err.Error())
+
+	for _, geometry := range []string{
+		`{"type":"Point","coordinates":[5]}`,
+		`{"type":"LineString","coordinates":[[0,0],[5]]}`,
+		`{"type":"MultiLineString","coordinates":[[[0,0]],[[]]]}`,
+	} {
+		_, err = ParseGeoJSONWithContent([]byte(`{"type":"FeatureCollection","features":[
+			{"type":"Feature","geometry":` + geometry + `,"properties":{}}]}`))
+		assert.NotEqual(t, nil, err, geometry)
+	}
+	_, err = ParseGeoJSONWithContent([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[5]},"properties":{}}]}`))
+	assert.Equal(t, `gpxgo: GeoJSON feature 0: position [5] without longitude and latitude`, err.Error())
+}
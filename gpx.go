@@ -8,6 +8,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"time"
 )
 
 type Waypoints []Wpt
@@ -32,11 +33,6 @@ type Link struct {
 	Type    string   `xml:"type,omitempty"`
 }
 
-type Extensions struct {
-	XMLName xml.Name `xml:"extensions"`
-	Info    string   `xml:",innerxml"`
-}
-
 type Copyright struct {
 	XMLName xml.Name `xml:"copyright,omitempty"`
 	Author  string   `xml:"author,attr"`
@@ -46,23 +42,24 @@ type Copyright struct {
 
 type Bounds struct {
 	XMLName xml.Name `xml:"bounds"`
-	MinLat  float64  `xml:"minlat,omitempty"`
-	MinLon  float64  `xml:"minlon,omitempty"`
-	MaxLat  float64  `xml:"maxlat,omitempty"`
-	MaxLon  float64  `xml:"maxlon,omitempty"`
+	MinLat  float64  `xml:"minlat,attr"`
+	MinLon  float64  `xml:"minlon,attr"`
+	MaxLat  float64  `xml:"maxlat,attr"`
+	MaxLon  float64  `xml:"maxlon,attr"`
 }
 
 type Metadata struct {
-	XMLName    xml.Name    `xml:"metadata"`
-	Name       string      `xml:"name,omitempty"`
-	Desc       string      `xml:"desc,omitempty"`
-	Author     *Person     `xml:"author,omitempty"`
-	Copyright  *Copyright  `xml:"copyright,omitempty"`
-	Link       []Link      `xml:"link,omitempty"`
-	Time       string      `xml:"time,omitempty"`
-	Keywords   string      `xml:"keywords,omitempty"`
-	Bounds     *Bounds     `xml:"bounds"`
-	Extensions *Extensions `xml:"extensions,omitempty"`
+	XMLName    xml.Name     `xml:"metadata"`
+	Name       string       `xml:"name,omitempty"`
+	Desc       string       `xml:"desc,omitempty"`
+	Author     *Person      `xml:"author,omitempty"`
+	Copyright  *Copyright   `xml:"copyright,omitempty"`
+	Link       []Link       `xml:"link,omitempty"`
+	Time       NullableTime `xml:"time,omitempty"`
+	Keywords   string       `xml:"keywords,omitempty"`
+	Bounds     *Bounds      `xml:"bounds"`
+	Extensions *Extensions  `xml:"extensions,omitempty"`
+	Unknown    []Extension  `xml:",any"`
 }
 
 type Wpt struct {
@@ -71,98 +68,142 @@ type Wpt struct {
 	Lat float64 `xml:"lat,attr"`
 	Lon float64 `xml:"lon,attr"`
 	//
-	Ele           float64     `xml:"ele,omitempty"`
-	Time          string      `xml:"time,omitempty"`
-	Magvar        string      `xml:"magvar,omitempty"`
-	Geoidheight   string      `xml:"geoidheight,omitempty"`
-	Name          string      `xml:"name,omitempty"`
-	Cmt           string      `xml:"cmt,omitempty"`
-	Desc          string      `xml:"desc,omitempty"`
-	Src           string      `xml:"src,omitempty"`
-	Link          []Link      `xml:"link,omitempty"`
-	Sym           string      `xml:"sym,omitempty"`
-	Type          string      `xml:"type,omitempty"`
-	Fix           string      `xml:"fix,omitempty"`
-	Sat           uint        `xml:"sat,omitempty"`
-	Hdop          float64     `xml:"hdop,omitempty"`
-	Vdop          float64     `xml:"vdop,omitempty"`
-	Pdop          float64     `xml:"pdop,omitempty"`
-	Ageofdgpsdata float64     `xml:"ageofdgpsdata,omitempty"`
-	Dgpsid        int         `xml:"dgpsid,omitempty"`
-	Extensions    *Extensions `xml:"extensions,omitempty"`
+	Ele           NullableFloat64 `xml:"ele,omitempty"`
+	Time          NullableTime    `xml:"time,omitempty"`
+	Magvar        string          `xml:"magvar,omitempty"`
+	Geoidheight   string          `xml:"geoidheight,omitempty"`
+	Name          string          `xml:"name,omitempty"`
+	Cmt           string          `xml:"cmt,omitempty"`
+	Desc          string          `xml:"desc,omitempty"`
+	Src           string          `xml:"src,omitempty"`
+	Link          []Link          `xml:"link,omitempty"`
+	Sym           string          `xml:"sym,omitempty"`
+	Type          string          `xml:"type,omitempty"`
+	Fix           string          `xml:"fix,omitempty"`
+	Sat           NullableInt     `xml:"sat,omitempty"`
+	Hdop          NullableFloat64 `xml:"hdop,omitempty"`
+	Vdop          NullableFloat64 `xml:"vdop,omitempty"`
+	Pdop          NullableFloat64 `xml:"pdop,omitempty"`
+	Ageofdgpsdata NullableFloat64 `xml:"ageofdgpsdata,omitempty"`
+	Dgpsid        int             `xml:"dgpsid,omitempty"`
+	Extensions    *Extensions     `xml:"extensions,omitempty"`
+	Unknown       []Extension     `xml:",any"`
+	// GPX 1.0 only, in m/s and degrees
+	Speed  NullableFloat64 `xml:"-"`
+	Course NullableFloat64 `xml:"-"`
 }
 
 type Rte struct {
-	XMLName    xml.Name  `xml:"rte"`
-	Name       string    `xml:"name,omitempty"`
-	Cmt        string    `xml:"cmt,omitempty"`
-	Desc       string    `xml:"desc,omitempty"`
-	Src        string    `xml:"src,omitempty"`
-	Link       []Link    `xml:"link,omitempty"`
-	Number     uint      `xml:"number,omitempty"`
-	Type       string    `xml:"type,omitempty"`
-	Extensions string    `xml:"extensions,omitempty"`
-	Waypoints  Waypoints `xml:"rtept,omitempty"`
+	XMLName    xml.Name    `xml:"rte"`
+	Name       string      `xml:"name,omitempty"`
+	Cmt        string      `xml:"cmt,omitempty"`
+	Desc       string      `xml:"desc,omitempty"`
+	Src        string      `xml:"src,omitempty"`
+	Link       []Link      `xml:"link,omitempty"`
+	Number     uint        `xml:"number,omitempty"`
+	Type       string      `xml:"type,omitempty"`
+	Extensions *Extensions `xml:"extensions,omitempty"`
+	Waypoints  Waypoints   `xml:"rtept,omitempty"`
+	Unknown    []Extension `xml:",any"`
 }
 
 type Trkseg struct {
 	XMLName    xml.Name    `xml:"trkseg"`
 	Waypoints  Waypoints   `xml:"trkpt"`
 	Extensions *Extensions `xml:"extensions,omitempty"`
+	Unknown    []Extension `xml:",any"`
 }
 
 type Trk struct {
-	XMLName    xml.Name `xml:"trk"`
-	Name       string   `xml:"name,omitempty"`
-	Cmt        string   `xml:"cmt,omitempty"`
-	Desc       string   `xml:"desc,omitempty"`
-	Src        string   `xml:"src,omitempty"`
-	Link       []Link   `xml:"link,omitempty"`
-	Number     uint     `xml:"number,omitempty"`
-	Type       string   `xml:"type,omitempty"`
-	Extensions string   `xml:"extensions,omitempty"`
-	Segments   []Trkseg `xml:"trkseg,omitempty"`
+	XMLName    xml.Name    `xml:"trk"`
+	Name       string      `xml:"name,omitempty"`
+	Cmt        string      `xml:"cmt,omitempty"`
+	Desc       string      `xml:"desc,omitempty"`
+	Src        string      `xml:"src,omitempty"`
+	Link       []Link      `xml:"link,omitempty"`
+	Number     uint        `xml:"number,omitempty"`
+	Type       string      `xml:"type,omitempty"`
+	Extensions *Extensions `xml:"extensions,omitempty"`
+	Segments   []Trkseg    `xml:"trkseg,omitempty"`
+	Unknown    []Extension `xml:",any"`
 }
 
 type Gpx struct {
-	XMLName      xml.Name  `xml:"gpx"`
-	XMLNs        string    `xml:"xmlns,attr"`
-	XMLNsXsi     string    `xml:"xmlns:xsi,attr"`
-	XMLSchemaLoc string    `xml:"xsi:schemaLocation,attr"`
-	Version      string    `xml:"version,attr"`
-	Creator      string    `xml:"creator,attr"`
-	Metadata     *Metadata `xml:"metadata,omitempty"`
-	Waypoints    Waypoints `xml:"wpt,omitempty"`
-	Routes       []Rte     `xml:"rte,omitempty"`
-	Tracks       []Trk     `xml:"trk,omitempty"`
-	Extensions   string    `xml:"extensions,omitempty"`
+	XMLName      xml.Name    `xml:"gpx"`
+	XMLNs        string      `xml:"xmlns,attr"`
+	XMLNsXsi     string      `xml:"xmlns:xsi,attr,omitempty"`
+	XMLSchemaLoc string      `xml:"xsi:schemaLocation,attr,omitempty"`
+	Version      string      `xml:"version,attr"`
+	Creator      string      `xml:"creator,attr"`
+	Metadata     *Metadata   `xml:"metadata,omitempty"`
+	Waypoints    Waypoints   `xml:"wpt,omitempty"`
+	Routes       []Rte       `xml:"rte,omitempty"`
+	Tracks       []Trk       `xml:"trk,omitempty"`
+	Extensions   *Extensions `xml:"extensions,omitempty"`
+	Unknown      []Extension `xml:",any"`
+	// Other attributes of <gpx>, e.g. namespace declarations, with their
+	// qualified name in Name.Local. Only kept by ParseOptions.Preserve.
+	Attrs []xml.Attr `xml:",any,attr"`
+	// The points skipped by ParseOptions.Lenient
+	Warnings []*ParseError `xml:"-"`
 }
 
 type TimeBounds struct {
-	StartTime float64
-	EndTime   float64
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 /*==========================================================*/
 // Static
 func ParseWithContent(content []byte) (*Gpx, error) {
-	gpx := NewGpx()
-	err := xml.Unmarshal(content, gpx)
-	if err != nil {
-		return nil, err
-	}
-	return gpx, nil
+	return ParseWithReader(bytes.NewReader(content))
 }
 
+// ParseWithReader parses GPX 1.1 and GPX 1.0 documents. GPX 1.0 is converted
+// to the 1.1 model, with Version kept as "1.0". Gzip and bzip2 compressed
+// documents are decompressed.
 func ParseWithReader(o io.Reader) (*Gpx, error) {
-	gpx := NewGpx()
-	d := xml.NewDecoder(o)
-	d.CharsetReader = charset.NewReaderLabel
-	err := d.Decode(gpx)
+	return ParseWithOptions(o, ParseOptions{})
+}
+
+// ParseWithOptions parses like ParseWithReader, as set by opts. Errors of the
+// document are *ParseError, except io.EOF for a document without elements.
+func ParseWithOptions(o io.Reader, opts ParseOptions) (*Gpx, error) {
+	o, err := decompress(o)
 	if err != nil {
 		return nil, err
 	}
-	return gpx, nil
+	d := xml.NewDecoder(o)
+	d.CharsetReader = charset.NewReaderLabel
+	tracker := newTokenTracker(d, opts.Lenient)
+	td := xml.NewTokenDecoder(tracker)
+	for {
+		token, err := td.Token()
+		if err != nil {
+			return nil, tracker.wrap(err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var gpx *Gpx
+		if isGpx10(start) {
+			g10 := new(gpx10)
+			if err := td.DecodeElement(g10, &start); err != nil {
+				return nil, tracker.wrap(err)
+			}
+			gpx = g10.toGpx()
+		} else {
+			gpx = NewGpx()
+			if err := td.DecodeElement(gpx, &start); err != nil {
+				return nil, tracker.wrap(err)
+			}
+		}
+		gpx.Warnings = tracker.warnings
+		opts.apply(gpx)
+		return gpx, nil
+	}
 }
 
 func ParseWithPath(path string) (*Gpx, error) {
@@ -177,9 +218,9 @@ func ParseWithPath(path string) (*Gpx, error) {
 
 func NewGpx() *Gpx {
 	return &Gpx{
-		XMLNs:        "http://www.topografix.com/GPX/1/1",
-		XMLNsXsi:     "http://www.w3.org/2001/XMLSchema-instance",
-		XMLSchemaLoc: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd",
+		XMLNs:        GPX11_NAMESPACE,
+		XMLNsXsi:     XSI_NAMESPACE,
+		XMLSchemaLoc: GPX11_SCHEMA_LOCATION,
 		Version:      "1.1",
 		Creator:      "https://github.com/pikeszfish/gpxgo",
 	}
@@ -208,17 +249,66 @@ func (b Bounds) String() string {
 		b.MinLat, b.MinLon, b.MaxLat, b.MaxLon)
 }
 
+func mergeElevationExtremes(min, max, min2, max2 NullableFloat64) (NullableFloat64, NullableFloat64) {
+	if min2.Valid && (!min.Valid || min2.Float64 < min.Float64) {
+		min = min2
+	}
+	if max2.Valid && (!max.Valid || max2.Float64 > max.Float64) {
+		max = max2
+	}
+	return min, max
+}
+
 /*==========================================================*/
-// Gpx
-func toXML(n interface{}) []byte {
-	content, _ := xml.MarshalIndent(n, "", "  ")
-	return content
+// TimeBounds
+func timeBoundsOf(wps Waypoints) *TimeBounds {
+	var tb *TimeBounds
+	for _, wp := range wps {
+		if wp.Time.Valid {
+			tb = tb.merge(&TimeBounds{StartTime: wp.Time.Time, EndTime: wp.Time.Time})
+		}
+	}
+	return tb
+}
+
+func (tb *TimeBounds) merge(tb2 *TimeBounds) *TimeBounds {
+	if tb == nil {
+		return tb2
+	}
+	if tb2 == nil {
+		return tb
+	}
+	merged := *tb
+	if tb2.StartTime.Before(merged.StartTime) {
+		merged.StartTime = tb2.StartTime
+	}
+	if tb2.EndTime.After(merged.EndTime) {
+		merged.EndTime = tb2.EndTime
+	}
+	return &merged
+}
+
+func (tb *TimeBounds) Duration() time.Duration {
+	if tb == nil {
+		return 0
+	}
+	return tb.EndTime.Sub(tb.StartTime)
+}
+
+func (tb TimeBounds) String() string {
+	return fmt.Sprintf("Start: %s End: %s", FormatTime(tb.StartTime), FormatTime(tb.EndTime))
 }
 
+/*==========================================================*/
+// Gpx
+// ToXML writes the document in its Version, see ToXMLVersion. It returns nil
+// if the document cannot be written, such as for a registered extension value
+// that fails to marshal; WriteXML returns the error.
 func (g *Gpx) ToXML() []byte {
 	var buffer bytes.Buffer
-	buffer.WriteString(xml.Header)
-	buffer.Write(toXML(g))
+	if err := g.WriteXML(&buffer); err != nil {
+		return nil
+	}
 	return buffer.Bytes()
 }
 
@@ -229,6 +319,9 @@ func (g *Gpx) Clone() *Gpx {
 	newgpx.XMLSchemaLoc = g.XMLSchemaLoc
 	newgpx.Version = g.Version
 	newgpx.Creator = g.Creator
+	newgpx.Extensions = g.Extensions.copy()
+	newgpx.Unknown = g.Unknown
+	newgpx.Attrs = append([]xml.Attr(nil), g.Attrs...)
 
 	if g.Metadata != nil {
 		newgpx.Metadata = &Metadata{
@@ -237,7 +330,8 @@ func (g *Gpx) Clone() *Gpx {
 			Link:       make([]Link, len(g.Metadata.Link)),
 			Time:       g.Metadata.Time,
 			Keywords:   g.Metadata.Keywords,
-			Extensions: g.Metadata.Extensions,
+			Extensions: g.Metadata.Extensions.copy(),
+			Unknown:    g.Metadata.Unknown,
 		}
 		copy(newgpx.Metadata.Link, g.Metadata.Link)
 		if g.Metadata.Author != nil {
@@ -277,13 +371,41 @@ func (g *Gpx) Clone() *Gpx {
 	newgpx.Waypoints = make([]Wpt, len(g.Waypoints))
 	newgpx.Routes = make([]Rte, len(g.Routes))
 	newgpx.Tracks = make([]Trk, len(g.Tracks))
-	copy(newgpx.Waypoints, g.Waypoints)
+	for i := range g.Waypoints {
+		newgpx.Waypoints[i] = *g.Waypoints[i].DeepCopy()
+	}
 	copy(newgpx.Routes, g.Routes)
+	for i := range newgpx.Routes {
+		newgpx.Routes[i].Extensions = g.Routes[i].Extensions.copy()
+		newgpx.Routes[i].Waypoints = copyWaypoints(g.Routes[i].Waypoints)
+	}
 	copy(newgpx.Tracks, g.Tracks)
+	for i := range newgpx.Tracks {
+		trk := &newgpx.Tracks[i]
+		trk.Extensions = g.Tracks[i].Extensions.copy()
+		trk.Segments = make([]Trkseg, len(g.Tracks[i].Segments))
+		copy(trk.Segments, g.Tracks[i].Segments)
+		for j := range trk.Segments {
+			trk.Segments[j].Extensions = g.Tracks[i].Segments[j].Extensions.copy()
+			trk.Segments[j].Waypoints = copyWaypoints(g.Tracks[i].Segments[j].Waypoints)
+		}
+	}
 
 	return newgpx
 }
 
+// copyWaypoints returns deep copies of wps.
+func copyWaypoints(wps Waypoints) Waypoints {
+	if wps == nil {
+		return nil
+	}
+	copied := make(Waypoints, len(wps))
+	for i := range wps {
+		copied[i] = *wps[i].DeepCopy()
+	}
+	return copied
+}
+
 func (g *Gpx) Bounds() *Bounds {
 	b := minBounds()
 	for _, trk := range g.Tracks {
@@ -292,6 +414,20 @@ func (g *Gpx) Bounds() *Bounds {
 	return b
 }
 
+// TimeBounds returns the earliest and latest track point time, or nil if no
+// track point has a time.
+func (g *Gpx) TimeBounds() *TimeBounds {
+	var tb *TimeBounds
+	for i := range g.Tracks {
+		tb = tb.merge(g.Tracks[i].TimeBounds())
+	}
+	return tb
+}
+
+func (g *Gpx) Duration() time.Duration {
+	return g.TimeBounds().Duration()
+}
+
 func (g *Gpx) Length2D() float64 {
 	var length2d float64
 	for _, trk := range g.Tracks {
@@ -321,42 +457,51 @@ func (g *Gpx) UphillDownhill() (float64, float64) {
 	return uphill, downhill
 }
 
-func (g *Gpx) ElevationExtremes() (min float64, max float64) {
-	var (
-		elevations []float64
-	)
+func (g *Gpx) ElevationExtremes() (min NullableFloat64, max NullableFloat64) {
 	for _, trk := range g.Tracks {
-		min, max = trk.ElevationExtremes()
-		elevations = append(elevations, min)
-		elevations = append(elevations, max)
-	}
-	min = elevations[0]
-	max = elevations[0]
-	for _, elevation := range elevations {
-		if elevation < min {
-			min = elevation
-		}
-		if elevation > max {
-			max = elevation
-		}
+		trkMin, trkMax := trk.ElevationExtremes()
+		min, max = mergeElevationExtremes(min, max, trkMin, trkMax)
 	}
 	return min, max
 }
 
 func (g *Gpx) RemoveTime() {
-	for _, trk := range g.Tracks {
-		trk.RemoveTime()
+	if g.Metadata != nil {
+		g.Metadata.Time.SetNull()
+	}
+	for i := range g.Waypoints {
+		g.Waypoints[i].RemoveTime()
+	}
+	for i := range g.Routes {
+		g.Routes[i].RemoveTime()
+	}
+	for i := range g.Tracks {
+		g.Tracks[i].RemoveTime()
 	}
 }
 
 func (g *Gpx) RemoveElevation() {
-	for _, trk := range g.Tracks {
-		trk.RemoveElevation()
+	for i := range g.Waypoints {
+		g.Waypoints[i].RemoveElevation()
+	}
+	for i := range g.Routes {
+		g.Routes[i].RemoveElevation()
+	}
+	for i := range g.Tracks {
+		g.Tracks[i].RemoveElevation()
 	}
 }
 
 /*==========================================================*/
 // Routes
+func (r *Rte) TimeBounds() *TimeBounds {
+	return timeBoundsOf(r.Waypoints)
+}
+
+func (r *Rte) Duration() time.Duration {
+	return r.TimeBounds().Duration()
+}
+
 func (r *Rte) Length2D() float64 {
 	var length2d float64
 	for i := 1; i < len(r.Waypoints); i++ {
@@ -374,14 +519,14 @@ func (r *Rte) Length3D() float64 {
 }
 
 func (r *Rte) RemoveTime() {
-	for _, waypoint := range r.Waypoints {
-		waypoint.RemoveTime()
+	for i := range r.Waypoints {
+		r.Waypoints[i].RemoveTime()
 	}
 }
 
 func (r *Rte) RemoveElevation() {
-	for _, waypoint := range r.Waypoints {
-		waypoint.RemoveElevation()
+	for i := range r.Waypoints {
+		r.Waypoints[i].RemoveElevation()
 	}
 }
 
@@ -395,6 +540,18 @@ func (t *Trk) Bounds() *Bounds {
 	return b
 }
 
+func (t *Trk) TimeBounds() *TimeBounds {
+	var tb *TimeBounds
+	for i := range t.Segments {
+		tb = tb.merge(t.Segments[i].TimeBounds())
+	}
+	return tb
+}
+
+func (t *Trk) Duration() time.Duration {
+	return t.TimeBounds().Duration()
+}
+
 func (t *Trk) Length2D() float64 {
 	var length2d float64
 	for _, seg := range t.Segments {
@@ -412,8 +569,8 @@ func (t *Trk) Length3D() float64 {
 }
 
 func (t *Trk) RemoveTime() {
-	for _, seg := range t.Segments {
-		seg.RemoveTime()
+	for i := range t.Segments {
+		t.Segments[i].RemoveTime()
 	}
 }
 
@@ -430,31 +587,17 @@ func (t *Trk) UphillDownhill() (float64, float64) {
 	return uphill, downhill
 }
 
-func (t *Trk) ElevationExtremes() (min float64, max float64) {
-	var (
-		elevations []float64
-	)
+func (t *Trk) ElevationExtremes() (min NullableFloat64, max NullableFloat64) {
 	for _, seg := range t.Segments {
-		min, max = seg.ElevationExtremes()
-		elevations = append(elevations, min)
-		elevations = append(elevations, max)
-	}
-	min = elevations[0]
-	max = elevations[0]
-	for _, elevation := range elevations {
-		if elevation < min {
-			min = elevation
-		}
-		if elevation > max {
-			max = elevation
-		}
+		segMin, segMax := seg.ElevationExtremes()
+		min, max = mergeElevationExtremes(min, max, segMin, segMax)
 	}
 	return min, max
 }
 
 func (t *Trk) RemoveElevation() {
-	for _, seg := range t.Segments {
-		seg.RemoveElevation()
+	for i := range t.Segments {
+		t.Segments[i].RemoveElevation()
 	}
 }
 
@@ -477,6 +620,14 @@ func (ts *Trkseg) Bounds() *Bounds {
 	return b
 }
 
+func (ts *Trkseg) TimeBounds() *TimeBounds {
+	return timeBoundsOf(ts.Waypoints)
+}
+
+func (ts *Trkseg) Duration() time.Duration {
+	return ts.TimeBounds().Duration()
+}
+
 func (ts *Trkseg) Length2D() float64 {
 	var length2d float64
 	for i := 1; i < len(ts.Waypoints); i++ {
@@ -499,30 +650,29 @@ func (ts Trkseg) String() string {
 
 func (ts *Trkseg) UphillDownhill() (uphill float64, downhill float64) {
 	var (
-		smoothedElevations               []float64
-		previousEle, currentEle, nextEle float64
-		f                                float64
+		elevations         []float64
+		smoothedElevations []float64
 	)
-	if ts.Waypoints == nil || len(ts.Waypoints) <= 1 {
+	for _, wp := range ts.Waypoints {
+		if wp.Ele.Valid {
+			elevations = append(elevations, wp.Ele.Float64)
+		}
+	}
+	if len(elevations) <= 1 {
 		return 0.0, 0.0
 	}
 
-	smoothedElevations = append(smoothedElevations, ts.Waypoints[0])
-	for i := 0; i < len(ts.Waypoints); i++ {
-		if i > 0 && i < len(ts.Waypoints)-1 {
-			previousEle = ts.Waypoints[i-1].Ele
-			currentEle = ts.Waypoints[i].Ele
-			nextEle = ts.Waypoints[i+1].Ele
-			smoothedElevations = append(smoothedElevations, previousEle*0.3+currentEle*0.4+nextEle*0.3)
-		}
+	smoothedElevations = append(smoothedElevations, elevations[0])
+	for i := 1; i < len(elevations)-1; i++ {
+		smoothedElevations = append(smoothedElevations, elevations[i-1]*0.3+elevations[i]*0.4+elevations[i+1]*0.3)
 	}
-	smoothedElevations = append(smoothedElevations, ts.Waypoints[len(ts.Waypoints)-1])
+	smoothedElevations = append(smoothedElevations, elevations[len(elevations)-1])
 
 	for index, ele := range smoothedElevations {
 		if index == 0 {
 			continue
 		}
-		d = ele - smoothedElevations[index-1]
+		d := ele - smoothedElevations[index-1]
 		if d > 0 {
 			uphill += d
 		} else {
@@ -532,29 +682,22 @@ func (ts *Trkseg) UphillDownhill() (uphill float64, downhill float64) {
 	return uphill, downhill
 }
 
-func (ts *Trkseg) ElevationExtremes() (min float64, max float64) {
-	min = 0.0
-	max = 0.0
+func (ts *Trkseg) ElevationExtremes() (min NullableFloat64, max NullableFloat64) {
 	for _, wp := range ts.Waypoints {
-		if wp.Ele < min {
-			min = wp.Ele
-		}
-		if wp.Ele > max {
-			max = wp.Ele
-		}
+		min, max = mergeElevationExtremes(min, max, wp.Ele, wp.Ele)
 	}
 	return min, max
 }
 
 func (ts *Trkseg) RemoveTime() {
-	for _, wp := range ts.Waypoints {
-		wp.RemoveTime()
+	for i := range ts.Waypoints {
+		ts.Waypoints[i].RemoveTime()
 	}
 }
 
 func (ts *Trkseg) RemoveElevation() {
-	for _, wp := range ts.Waypoints {
-		wp.RemoveElevation()
+	for i := range ts.Waypoints {
+		ts.Waypoints[i].RemoveElevation()
 	}
 }
 
@@ -569,15 +712,18 @@ func (wp *Wpt) Length2D(wp2 *Wpt) float64 {
 }
 
 func (wp *Wpt) Length3D(wp2 *Wpt) float64 {
-	return Distance(wp.Lat, wp.Lon, wp.Ele, wp2.Lat, wp2.Lon, wp2.Ele, true, false)
+	if !wp.Ele.Valid || !wp2.Ele.Valid {
+		return wp.Length2D(wp2)
+	}
+	return Distance(wp.Lat, wp.Lon, wp.Ele.Float64, wp2.Lat, wp2.Lon, wp2.Ele.Float64, true, false)
 }
 
 func (wp *Wpt) RemoveTime() {
-	wp.Time = ""
+	wp.Time.SetNull()
 }
 
 func (wp *Wpt) RemoveElevation() {
-	wp.Ele = 0.0
+	wp.Ele.SetNull()
 }
 
 func (wp *Wpt) DeepCopy() *Wpt {
@@ -602,7 +748,10 @@ func (wp *Wpt) DeepCopy() *Wpt {
 		Pdop:          wp.Pdop,
 		Ageofdgpsdata: wp.Ageofdgpsdata,
 		Dgpsid:        wp.Dgpsid,
-		Extensions:    wp.Extensions,
+		Extensions:    wp.Extensions.copy(),
+		Unknown:       wp.Unknown,
+		Speed:         wp.Speed,
+		Course:        wp.Course,
 	}
 	copy(newWpt.Link, wp.Link)
 	return newWpt
@@ -612,6 +761,28 @@ func (wp *Wpt) Move(ld *LocationDelta) {
 	ld.Move(wp)
 }
 
+// Interpolate returns the point at fraction (0..1) of the way to wp2 along the
+// great circle. Elevation, time and the numeric values of registered
+// extensions, such as heart rate, are interpolated linearly when both points
+// have them; the extensions are otherwise a copy of those of the nearer point.
+func (wp *Wpt) Interpolate(wp2 *Wpt, fraction float64) *Wpt {
+	ld := &LocationDelta{
+		Angle:    Bearing(wp.Lat, wp.Lon, wp2.Lat, wp2.Lon),
+		Distance: HaversineDistance(wp.Lat, wp.Lon, wp2.Lat, wp2.Lon) * fraction,
+	}
+	newWpt := &Wpt{Lat: wp.Lat, Lon: wp.Lon}
+	newWpt.Move(ld)
+	if wp.Ele.Valid && wp2.Ele.Valid {
+		newWpt.Ele.SetValue(wp.Ele.Float64 + (wp2.Ele.Float64-wp.Ele.Float64)*fraction)
+	}
+	if wp.Time.Valid && wp2.Time.Valid {
+		elapsed := wp2.Time.Time.Sub(wp.Time.Time)
+		newWpt.Time.SetValue(wp.Time.Time.Add(time.Duration(float64(elapsed) * fraction)))
+	}
+	newWpt.Extensions = interpolateExtensions(wp.Extensions, wp2.Extensions, fraction)
+	return newWpt
+}
+
 func (wp *Wpt) DistanceAngle(wp2 *Wpt) *LocationDelta {
 	return &LocationDelta{
 		Angle:    Bearing(wp.Lat, wp.Lon, wp2.Lat, wp2.Lon),
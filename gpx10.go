@@ -0,0 +1,417 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	GPX10_NAMESPACE = "http://www.topografix.com/GPX/1/0"
+	GPX11_NAMESPACE = "http://www.topografix.com/GPX/1/1"
+	XSI_NAMESPACE   = "http://www.w3.org/2001/XMLSchema-instance"
+
+	GPX10_SCHEMA_LOCATION = GPX10_NAMESPACE + " " + GPX10_NAMESPACE + "/gpx.xsd"
+	GPX11_SCHEMA_LOCATION = GPX11_NAMESPACE + " " + GPX11_NAMESPACE + "/gpx.xsd"
+)
+
+// GPX 1.0 documents are decoded into the types below and converted to the
+// GPX 1.1 model. They are also used to write a Gpx back as GPX 1.0.
+
+type gpx10 struct {
+	XMLName      xml.Name     `xml:"gpx"`
+	XMLNs        string       `xml:"xmlns,attr"`
+	XMLNsXsi     string       `xml:"xmlns:xsi,attr,omitempty"`
+	XMLSchemaLoc string       `xml:"xsi:schemaLocation,attr,omitempty"`
+	Version      string       `xml:"version,attr"`
+	Creator      string       `xml:"creator,attr"`
+	Name         string       `xml:"name,omitempty"`
+	Desc         string       `xml:"desc,omitempty"`
+	Author       string       `xml:"author,omitempty"`
+	Email        string       `xml:"email,omitempty"`
+	URL          string       `xml:"url,omitempty"`
+	URLName      string       `xml:"urlname,omitempty"`
+	Time         NullableTime `xml:"time,omitempty"`
+	Keywords     string       `xml:"keywords,omitempty"`
+	Bounds       *bounds10    `xml:"bounds"`
+	Waypoints    []wpt10      `xml:"wpt"`
+	Routes       []rte10      `xml:"rte"`
+	Tracks       []trk10      `xml:"trk"`
+	Unknown      []Extension  `xml:",any"`
+	Attrs        []xml.Attr   `xml:",any,attr"`
+}
+
+type bounds10 struct {
+	MinLat float64 `xml:"minlat,attr"`
+	MinLon float64 `xml:"minlon,attr"`
+	MaxLat float64 `xml:"maxlat,attr"`
+	MaxLon float64 `xml:"maxlon,attr"`
+}
+
+type wpt10 struct {
+	Lat           float64         `xml:"lat,attr"`
+	Lon           float64         `xml:"lon,attr"`
+	Ele           NullableFloat64 `xml:"ele,omitempty"`
+	Time          NullableTime    `xml:"time,omitempty"`
+	Course        NullableFloat64 `xml:"course,omitempty"`
+	Speed         NullableFloat64 `xml:"speed,omitempty"`
+	Magvar        string          `xml:"magvar,omitempty"`
+	Geoidheight   string          `xml:"geoidheight,omitempty"`
+	Name          string          `xml:"name,omitempty"`
+	Cmt           string          `xml:"cmt,omitempty"`
+	Desc          string          `xml:"desc,omitempty"`
+	Src           string          `xml:"src,omitempty"`
+	URL           string          `xml:"url,omitempty"`
+	URLName       string          `xml:"urlname,omitempty"`
+	Sym           string          `xml:"sym,omitempty"`
+	Type          string          `xml:"type,omitempty"`
+	Fix           string          `xml:"fix,omitempty"`
+	Sat           NullableInt     `xml:"sat,omitempty"`
+	Hdop          NullableFloat64 `xml:"hdop,omitempty"`
+	Vdop          NullableFloat64 `xml:"vdop,omitempty"`
+	Pdop          NullableFloat64 `xml:"pdop,omitempty"`
+	Ageofdgpsdata NullableFloat64 `xml:"ageofdgpsdata,omitempty"`
+	Dgpsid        int             `xml:"dgpsid,omitempty"`
+	Unknown       []Extension     `xml:",any"`
+}
+
+type rte10 struct {
+	Name      string      `xml:"name,omitempty"`
+	Cmt       string      `xml:"cmt,omitempty"`
+	Desc      string      `xml:"desc,omitempty"`
+	Src       string      `xml:"src,omitempty"`
+	URL       string      `xml:"url,omitempty"`
+	URLName   string      `xml:"urlname,omitempty"`
+	Number    uint        `xml:"number,omitempty"`
+	Waypoints []wpt10     `xml:"rtept"`
+	Unknown   []Extension `xml:",any"`
+}
+
+type trkseg10 struct {
+	Waypoints []wpt10     `xml:"trkpt"`
+	Unknown   []Extension `xml:",any"`
+}
+
+type trk10 struct {
+	Name     string      `xml:"name,omitempty"`
+	Cmt      string      `xml:"cmt,omitempty"`
+	Desc     string      `xml:"desc,omitempty"`
+	Src      string      `xml:"src,omitempty"`
+	URL      string      `xml:"url,omitempty"`
+	URLName  string      `xml:"urlname,omitempty"`
+	Number   uint        `xml:"number,omitempty"`
+	Segments []trkseg10  `xml:"trkseg"`
+	Unknown  []Extension `xml:",any"`
+}
+
+/*==========================================================*/
+// Static
+func isGpx10(start xml.StartElement) bool {
+	if start.Name.Space == GPX10_NAMESPACE {
+		return true
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "version" && attr.Name.Space == "" {
+			return strings.TrimSpace(attr.Value) == "1.0"
+		}
+	}
+	return false
+}
+
+func toLinks(url, urlname string) []Link {
+	if url == "" {
+		return nil
+	}
+	return []Link{{Href: url, Text: urlname}}
+}
+
+func fromLinks(links []Link) (url, urlname string) {
+	if len(links) == 0 {
+		return "", ""
+	}
+	return links[0].Href, links[0].Text
+}
+
+func toEmail(email string) *Email {
+	if email == "" {
+		return nil
+	}
+	parts := strings.SplitN(email, "@", 2)
+	if len(parts) != 2 {
+		return &Email{Id: email}
+	}
+	return &Email{Id: parts[0], Domain: parts[1]}
+}
+
+/*==========================================================*/
+// gpx10
+func (g10 *gpx10) toGpx() *Gpx {
+	gpx := &Gpx{
+		XMLNs:        GPX10_NAMESPACE,
+		XMLNsXsi:     XSI_NAMESPACE,
+		XMLSchemaLoc: GPX10_SCHEMA_LOCATION,
+		Version:      "1.0",
+		Creator:      g10.Creator,
+		Unknown:      g10.Unknown,
+		Attrs:        g10.Attrs,
+	}
+
+	gpx.Metadata = g10.toMetadata()
+	for _, w10 := range g10.Waypoints {
+		gpx.Waypoints = append(gpx.Waypoints, w10.toWpt())
+	}
+	for i := range g10.Routes {
+		gpx.Routes = append(gpx.Routes, g10.Routes[i].toRte())
+	}
+	for i := range g10.Tracks {
+		gpx.Tracks = append(gpx.Tracks, g10.Tracks[i].toTrk())
+	}
+	return gpx
+}
+
+// toMetadata returns the top-level GPX 1.0 fields as Metadata, or nil if
+// there are none.
+func (g10 *gpx10) toMetadata() *Metadata {
+	metadata := &Metadata{
+		Name:     g10.Name,
+		Desc:     g10.Desc,
+		Link:     toLinks(g10.URL, g10.URLName),
+		Time:     g10.Time,
+		Keywords: g10.Keywords,
+	}
+	if g10.Author != "" || g10.Email != "" {
+		metadata.Author = &Person{Name: g10.Author, Email: toEmail(g10.Email)}
+	}
+	if g10.Bounds != nil {
+		metadata.Bounds = &Bounds{
+			MinLat: g10.Bounds.MinLat,
+			MinLon: g10.Bounds.MinLon,
+			MaxLat: g10.Bounds.MaxLat,
+			MaxLon: g10.Bounds.MaxLon,
+		}
+	}
+	if metadata.Name != "" || metadata.Desc != "" || metadata.Link != nil || metadata.Time.Valid ||
+		metadata.Keywords != "" || metadata.Author != nil || metadata.Bounds != nil {
+		return metadata
+	}
+	return nil
+}
+
+func (r10 *rte10) toRte() Rte {
+	rte := Rte{
+		Name:    r10.Name,
+		Cmt:     r10.Cmt,
+		Desc:    r10.Desc,
+		Src:     r10.Src,
+		Link:    toLinks(r10.URL, r10.URLName),
+		Number:  r10.Number,
+		Unknown: r10.Unknown,
+	}
+	for _, w10 := range r10.Waypoints {
+		rte.Waypoints = append(rte.Waypoints, w10.toWpt())
+	}
+	return rte
+}
+
+func (t10 *trk10) toTrk() Trk {
+	trk := Trk{
+		Name:    t10.Name,
+		Cmt:     t10.Cmt,
+		Desc:    t10.Desc,
+		Src:     t10.Src,
+		Link:    toLinks(t10.URL, t10.URLName),
+		Number:  t10.Number,
+		Unknown: t10.Unknown,
+	}
+	for _, s10 := range t10.Segments {
+		seg := Trkseg{Unknown: s10.Unknown}
+		for _, w10 := range s10.Waypoints {
+			seg.Waypoints = append(seg.Waypoints, w10.toWpt())
+		}
+		trk.Segments = append(trk.Segments, seg)
+	}
+	return trk
+}
+
+func (w10 *wpt10) toWpt() Wpt {
+	return Wpt{
+		Lat:           w10.Lat,
+		Lon:           w10.Lon,
+		Ele:           w10.Ele,
+		Time:          w10.Time,
+		Magvar:        w10.Magvar,
+		Geoidheight:   w10.Geoidheight,
+		Name:          w10.Name,
+		Cmt:           w10.Cmt,
+		Desc:          w10.Desc,
+		Src:           w10.Src,
+		Link:          toLinks(w10.URL, w10.URLName),
+		Sym:           w10.Sym,
+		Type:          w10.Type,
+		Fix:           w10.Fix,
+		Sat:           w10.Sat,
+		Hdop:          w10.Hdop,
+		Vdop:          w10.Vdop,
+		Pdop:          w10.Pdop,
+		Ageofdgpsdata: w10.Ageofdgpsdata,
+		Dgpsid:        w10.Dgpsid,
+		Speed:         w10.Speed,
+		Course:        w10.Course,
+		Unknown:       w10.Unknown,
+	}
+}
+
+/*==========================================================*/
+// Gpx
+func (g *Gpx) toGpx10() *gpx10 {
+	g10 := &gpx10{
+		XMLNs:        GPX10_NAMESPACE,
+		XMLNsXsi:     XSI_NAMESPACE,
+		XMLSchemaLoc: GPX10_SCHEMA_LOCATION,
+		Version:      "1.0",
+		Creator:      g.Creator,
+		Unknown:      g.Unknown,
+		Attrs:        g.Attrs,
+	}
+	// A GPX 1.0 document read keeps its schema attributes, e.g. none.
+	if g.XMLNs == GPX10_NAMESPACE {
+		g10.XMLNsXsi, g10.XMLSchemaLoc = g.XMLNsXsi, g.XMLSchemaLoc
+	}
+
+	if g.Metadata != nil {
+		g10.Name = g.Metadata.Name
+		g10.Desc = g.Metadata.Desc
+		g10.URL, g10.URLName = fromLinks(g.Metadata.Link)
+		g10.Time = g.Metadata.Time
+		g10.Keywords = g.Metadata.Keywords
+		if author := g.Metadata.Author; author != nil {
+			g10.Author = author.Name
+			if author.Email != nil && author.Email.Id != "" {
+				g10.Email = author.Email.Id
+				if author.Email.Domain != "" {
+					g10.Email += "@" + author.Email.Domain
+				}
+			}
+			if g10.URL == "" && author.Link != nil {
+				g10.URL, g10.URLName = author.Link.Href, author.Link.Text
+			}
+		}
+		if b := g.Metadata.Bounds; b != nil {
+			g10.Bounds = &bounds10{MinLat: b.MinLat, MinLon: b.MinLon, MaxLat: b.MaxLat, MaxLon: b.MaxLon}
+		}
+	}
+
+	for i := range g.Waypoints {
+		g10.Waypoints = append(g10.Waypoints, g.Waypoints[i].toWpt10(false))
+	}
+	for i := range g.Routes {
+		g10.Routes = append(g10.Routes, g.Routes[i].toRte10())
+	}
+	for i := range g.Tracks {
+		g10.Tracks = append(g10.Tracks, g.Tracks[i].toTrk10())
+	}
+	return g10
+}
+
+// ToXMLVersion writes the document as GPX "1.0" or "1.1", whatever the
+// version it was parsed from. Data without a place in the target version is
+// dropped, e.g. speed and course in 1.1 or extensions in 1.0.
+func (g *Gpx) ToXMLVersion(version string) ([]byte, error) {
+	switch version {
+	case "1.0":
+		content, err := xml.MarshalIndent(g.toGpx10(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), content...), nil
+	case "1.1":
+		g11 := *g
+		if g11.Version != "1.1" {
+			g11.XMLNs = GPX11_NAMESPACE
+			g11.XMLNsXsi = XSI_NAMESPACE
+			g11.XMLSchemaLoc = GPX11_SCHEMA_LOCATION
+			g11.Version = "1.1"
+		}
+		var buffer bytes.Buffer
+		if err := g11.WriteXML(&buffer); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+	return nil, fmt.Errorf("gpxgo: unsupported GPX version %q", version)
+}
+
+/*==========================================================*/
+// Routes
+func (r *Rte) toRte10() rte10 {
+	r10 := rte10{
+		Name:    r.Name,
+		Cmt:     r.Cmt,
+		Desc:    r.Desc,
+		Src:     r.Src,
+		Number:  r.Number,
+		Unknown: r.Unknown,
+	}
+	r10.URL, r10.URLName = fromLinks(r.Link)
+	for i := range r.Waypoints {
+		r10.Waypoints = append(r10.Waypoints, r.Waypoints[i].toWpt10(false))
+	}
+	return r10
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) toTrk10() trk10 {
+	t10 := trk10{
+		Name:    t.Name,
+		Cmt:     t.Cmt,
+		Desc:    t.Desc,
+		Src:     t.Src,
+		Number:  t.Number,
+		Unknown: t.Unknown,
+	}
+	t10.URL, t10.URLName = fromLinks(t.Link)
+	for _, seg := range t.Segments {
+		s10 := trkseg10{Unknown: seg.Unknown}
+		for i := range seg.Waypoints {
+			s10.Waypoints = append(s10.Waypoints, seg.Waypoints[i].toWpt10(true))
+		}
+		t10.Segments = append(t10.Segments, s10)
+	}
+	return t10
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) toWpt10(withMotion bool) wpt10 {
+	w10 := wpt10{
+		Lat:           wp.Lat,
+		Lon:           wp.Lon,
+		Ele:           wp.Ele,
+		Time:          wp.Time,
+		Magvar:        wp.Magvar,
+		Geoidheight:   wp.Geoidheight,
+		Name:          wp.Name,
+		Cmt:           wp.Cmt,
+		Desc:          wp.Desc,
+		Src:           wp.Src,
+		Sym:           wp.Sym,
+		Type:          wp.Type,
+		Fix:           wp.Fix,
+		Sat:           wp.Sat,
+		Hdop:          wp.Hdop,
+		Vdop:          wp.Vdop,
+		Pdop:          wp.Pdop,
+		Ageofdgpsdata: wp.Ageofdgpsdata,
+		Dgpsid:        wp.Dgpsid,
+		Unknown:       wp.Unknown,
+	}
+	w10.URL, w10.URLName = fromLinks(wp.Link)
+	// GPX 1.0 only allows <course> and <speed> on track points.
+	if withMotion {
+		w10.Speed = wp.Speed
+		w10.Course = wp.Course
+	}
+	return w10
+}
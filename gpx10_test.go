@@ -0,0 +1,78 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGpx10(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/gpx10_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, "1.0", gpx.Version)
+	assert.Equal(t, "GPSBabel - http://www.gpsbabel.org", gpx.Creator)
+	assert.Equal(t, "Logger dump", gpx.Metadata.Name)
+	assert.Equal(t, "Morning ride", gpx.Metadata.Desc)
+	assert.Equal(t, "Jane Doe", gpx.Metadata.Author.Name)
+	assert.Equal(t, &Email{Id: "jane", Domain: "example.com"}, gpx.Metadata.Author.Email)
+	assert.Equal(t, []Link{{Href: "http://example.com/rides", Text: "Rides"}}, gpx.Metadata.Link)
+	assert.Equal(t, time.Date(2011, 5, 21, 9, 12, 0, 0, time.UTC), gpx.Metadata.Time.Time)
+	assert.Equal(t, 46.57635, gpx.Metadata.Bounds.MaxLat)
+
+	assert.Equal(t, "Start page", gpx.Waypoints[0].Link[0].Text)
+	assert.Equal(t, "B", gpx.Routes[0].Waypoints[1].Name)
+	assert.Equal(t, uint(1), gpx.Routes[0].Number)
+
+	trk := gpx.Tracks[0]
+	assert.Equal(t, "http://example.com/ride", trk.Link[0].Href)
+	wp := trk.Segments[0].Waypoints[0]
+	assert.Equal(t, NewNullableFloat64(4.2), wp.Speed)
+	assert.Equal(t, NewNullableFloat64(75.5), wp.Course)
+	assert.Equal(t, NewNullableInt(8), wp.Sat)
+	assert.Equal(t, NewNullableFloat64(0), trk.Segments[0].Waypoints[1].Speed)
+	assert.Equal(t, 10*time.Second, gpx.Duration())
+}
+
+func TestWriteGpx10(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/gpx10_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	// A parsed 1.0 document is written back as 1.0.
+	content := string(gpx.ToXML())
+	assert.Equal(t, true, strings.Contains(content, `xmlns="http://www.topografix.com/GPX/1/0"`))
+	assert.Equal(t, true, strings.Contains(content, `<email>jane@example.com</email>`))
+	assert.Equal(t, true, strings.Contains(content, `<speed>4.2</speed>`))
+	assert.Equal(t, false, strings.Contains(content, `<metadata>`))
+
+	reparsed, err := ParseWithContent([]byte(content))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, gpx, reparsed)
+
+	content11, err := gpx.ToXMLVersion("1.1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(string(content11), `xmlns="http://www.topografix.com/GPX/1/1"`))
+	assert.Equal(t, true, strings.Contains(string(content11), `<metadata>`))
+	assert.Equal(t, false, strings.Contains(string(content11), `<speed>`))
+	assert.Equal(t, "1.0", gpx.Version)
+
+	_, err = gpx.ToXMLVersion("2.0")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestWriteGpx11AsGpx10(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	content, err := gpx.ToXMLVersion("1.0")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(string(content), `<url>http://www.geovative.com/view?t=GEIF</url>`))
+
+	reparsed, err := ParseWithContent(content)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1.0", reparsed.Version)
+	assert.Equal(t, gpx.Metadata.Name, reparsed.Metadata.Name)
+	assert.Equal(t, len(gpx.Waypoints), len(reparsed.Waypoints))
+	assert.Equal(t, gpx.Length2D(), reparsed.Length2D())
+}
@@ -1,11 +1,14 @@
 package gpxgo
 
 import (
+	"encoding/xml"
 	"github.com/bmizerany/assert"
 	"log"
 	"math"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 var g *Gpx
@@ -22,7 +25,7 @@ func TestParseWithPath(t *testing.T) {
 	assert.Equal(t, nil, err)
 
 	assert.Equal(t, "St Louis Zoo sample", g.Metadata.Name)
-	assert.Equal(t, "2008-02-26T19:49:13", g.Metadata.Time)
+	assert.Equal(t, time.Date(2008, 2, 26, 19, 49, 13, 0, time.UTC), g.Metadata.Time.Time)
 
 	assert.Equal(t, 38.63473, g.Waypoints[0].Lat)
 	assert.Equal(t, -90.29408, g.Waypoints[0].Lon)
@@ -62,7 +65,7 @@ func TestParseWithContent(t *testing.T) {
 	assert.Equal(t, nil, err)
 
 	assert.Equal(t, "http://www.garmin.com", gpx.Metadata.Link[0].Href)
-	assert.Equal(t, "2009-10-17T22:58:43Z", gpx.Metadata.Time)
+	assert.Equal(t, "2009-10-17T22:58:43Z", gpx.Metadata.Time.String())
 
 	assert.Equal(t, "Example GPX Document", gpx.Tracks[0].Name)
 	assert.Equal(t, 3, len(gpx.Tracks[0].Segments[0].Waypoints))
@@ -85,23 +88,43 @@ func TestCloneGpx(t *testing.T) {
 	assert.Equal(t, newgpx.ToXML(), g.ToXML())
 }
 
+func TestCloneExtensions(t *testing.T) {
+	gpx := NewGpx()
+	gpx.Extensions = new(Extensions)
+	assert.Equal(t, nil, gpx.Extensions.AddXML(`<v:a xmlns:v="urn:vendor">1</v:a><v:b xmlns:v="urn:vendor">2</v:b>`))
+	gpx.Tracks = []Trk{{Segments: []Trkseg{{Waypoints: Waypoints{{Lat: 1, Lon: 2}}}}}}
+	wp := &gpx.Tracks[0].Segments[0].Waypoints[0]
+	wp.SetTrackPointExtension(&TrackPointExtension{HR: NewNullableInt(120)})
+	original := string(gpx.ToXML())
+
+	// Editing the extensions of copies leaves the original unchanged.
+	clone := gpx.Clone()
+	clone.Extensions.Set("urn:vendor", "a", nil)
+	clone.Tracks[0].Segments[0].Waypoints[0].SetTrackPointExtension(&TrackPointExtension{HR: NewNullableInt(150)})
+	wpCopy := wp.DeepCopy()
+	wpCopy.SetTrackPointExtension(&TrackPointExtension{HR: NewNullableInt(160)})
+	assert.Equal(t, original, string(gpx.ToXML()))
+	tpx, _ := clone.Tracks[0].Segments[0].Waypoints[0].TrackPointExtension()
+	assert.Equal(t, NewNullableInt(150), tpx.HR)
+}
+
 func TestNewXml(t *testing.T) {
 	gpx := NewGpx()
 	gpxTrack := Trk{}
 
 	gpxSegment := Trkseg{}
-	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1234, Lon: 121.1233, Ele: 1233})
-	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1235, Lon: 121.1234, Ele: 1234})
-	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1236, Lon: 121.1235, Ele: 1235})
+	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1234, Lon: 121.1233, Ele: NewNullableFloat64(1233)})
+	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1235, Lon: 121.1234, Ele: NewNullableFloat64(1234)})
+	gpxSegment.Waypoints = append(gpxSegment.Waypoints, Wpt{Lat: 32.1236, Lon: 121.1235, Ele: NewNullableFloat64(1235)})
 
 	gpxTrack.Segments = append(gpxTrack.Segments, gpxSegment)
 	gpx.Tracks = append(gpx.Tracks, gpxTrack)
 
-	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 1.1111, Lon: 9.9999, Ele: 1111})
-	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 2.2222, Lon: 8.8888, Ele: 2222})
-	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 3.3333, Lon: 7.7777, Ele: 3333})
-	actualXML := string(toXML(gpx))
-	expectedXML := `<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd" version="1.1" creator="https://github.com/pikeszfish/gpxgo">
+	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 1.1111, Lon: 9.9999, Ele: NewNullableFloat64(1111)})
+	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 2.2222, Lon: 8.8888, Ele: NewNullableFloat64(2222)})
+	gpx.Waypoints = append(gpx.Waypoints, Wpt{Lat: 3.3333, Lon: 7.7777, Ele: NewNullableFloat64(3333)})
+	actualXML := string(gpx.ToXML())
+	expectedXML := xml.Header + `<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd" version="1.1" creator="https://github.com/pikeszfish/gpxgo">
   <wpt lat="1.1111" lon="9.9999">
     <ele>1111</ele>
   </wpt>
@@ -133,7 +156,7 @@ func TestRemoveElevation(t *testing.T) {
 	for _, ts := range g.Tracks {
 		for _, seg := range ts.Segments {
 			for _, wp := range seg.Waypoints {
-				assert.Equal(t, 0.0, wp.Ele)
+				assert.Equal(t, true, wp.Ele.IsNull())
 			}
 		}
 	}
@@ -150,6 +173,14 @@ func TestBounds(t *testing.T) {
 	assert.Equal(t, sourceBounds, expectBounds)
 }
 
+func TestBoundsAttributes(t *testing.T) {
+	// The schema declares the bounds as attributes.
+	gpx, err := ParseWithContent([]byte(`<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="test"><metadata><bounds minlat="1.5" minlon="-2" maxlat="3" maxlon="4.25"/></metadata></gpx>`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &Bounds{XMLName: gpx.Metadata.Bounds.XMLName, MinLat: 1.5, MinLon: -2, MaxLat: 3, MaxLon: 4.25}, gpx.Metadata.Bounds)
+	assert.Equal(t, true, strings.Contains(string(gpx.ToXML()), `<bounds minlat="1.5" minlon="-2" maxlat="3" maxlon="4.25"></bounds>`))
+}
+
 func TestMoveWpt(t *testing.T) {
 	wp1 := &Wpt{Lat: 32.11111, Lon: 121.22222}
 	wp2 := &Wpt{Lat: 33.33333, Lon: 123.33333}
@@ -163,3 +194,73 @@ func TestMoveWpt(t *testing.T) {
 	assert.Equal(t, math.Abs(wp1.Lat-wp2.Lat) < 0.0000001, true)
 	assert.Equal(t, math.Abs(wp1.Lon-wp2.Lon) < 0.0000001, true)
 }
+
+func TestSeaLevelElevation(t *testing.T) {
+	buf := []byte(`<gpx version="1.1"><trk><trkseg>
+		<trkpt lat="1" lon="1"><ele>0</ele><sat>0</sat></trkpt>
+		<trkpt lat="1" lon="1.0001"></trkpt>
+		<trkpt lat="1" lon="1.0002"><ele>-2.5</ele><hdop>0</hdop></trkpt>
+	</trkseg></trk></gpx>`)
+	gpx, err := ParseWithContent(buf)
+	assert.Equal(t, nil, err)
+
+	wps := gpx.Tracks[0].Segments[0].Waypoints
+	assert.Equal(t, NewNullableFloat64(0), wps[0].Ele)
+	assert.Equal(t, NewNullableInt(0), wps[0].Sat)
+	assert.Equal(t, true, wps[1].Ele.IsNull())
+	assert.Equal(t, true, wps[1].Sat.IsNull())
+	assert.Equal(t, NewNullableFloat64(0), wps[2].Hdop)
+
+	content := string(gpx.ToXML())
+	assert.Equal(t, 2, strings.Count(content, "<ele>"))
+	assert.Equal(t, true, strings.Contains(content, "<ele>0</ele>"))
+	assert.Equal(t, true, strings.Contains(content, "<sat>0</sat>"))
+	assert.Equal(t, true, strings.Contains(content, "<hdop>0</hdop>"))
+
+	min, max := gpx.ElevationExtremes()
+	assert.Equal(t, NewNullableFloat64(-2.5), min)
+	assert.Equal(t, NewNullableFloat64(0), max)
+
+	uphill, downhill := gpx.UphillDownhill()
+	assert.Equal(t, 0.0, uphill)
+	assert.Equal(t, 2.5, downhill)
+}
+
+func TestElevationExtremesWithoutElevation(t *testing.T) {
+	min, max := NewGpx().ElevationExtremes()
+	assert.Equal(t, true, min.IsNull())
+	assert.Equal(t, true, max.IsNull())
+
+	min, max = g.ElevationExtremes()
+	assert.Equal(t, true, min.IsNull())
+	assert.Equal(t, true, max.IsNull())
+}
+
+func TestTimeBounds(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	tb := gpx.TimeBounds()
+	assert.NotEqual(t, (*TimeBounds)(nil), tb)
+	assert.Equal(t, time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC), tb.StartTime)
+	assert.Equal(t, tb.EndTime.Sub(tb.StartTime), gpx.Duration())
+	assert.Equal(t, gpx.Duration(), gpx.Tracks[0].Duration())
+
+	gpx.RemoveTime()
+	assert.Equal(t, (*TimeBounds)(nil), gpx.TimeBounds())
+	assert.Equal(t, time.Duration(0), gpx.Duration())
+}
+
+func TestTimeBoundsSkipsMissingTime(t *testing.T) {
+	start := time.Date(2009, 10, 17, 18, 37, 26, 0, time.UTC)
+	rte := Rte{}
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 1, Lon: 1})
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 1, Lon: 2, Time: NewNullableTime(start.Add(time.Minute))})
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 1, Lon: 3, Time: NewNullableTime(start)})
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 1, Lon: 4})
+
+	tb := rte.TimeBounds()
+	assert.Equal(t, start, tb.StartTime)
+	assert.Equal(t, start.Add(time.Minute), tb.EndTime)
+	assert.Equal(t, time.Minute, rte.Duration())
+}
@@ -0,0 +1,98 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultLocation is used for timestamps that carry no zone information,
+// e.g. "2008-02-26T19:49:13".
+var DefaultLocation = time.UTC
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// NullableTime is a GPX timestamp. Valid is false when the element is missing.
+type NullableTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+/*==========================================================*/
+// Static
+func NewNullableTime(t time.Time) NullableTime {
+	return NullableTime{Time: t, Valid: true}
+}
+
+// ParseTime parses the timestamp variants found in the wild. Timestamps
+// without a zone are interpreted in DefaultLocation.
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, value, DefaultLocation)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("gpxgo: invalid time %q", value)
+}
+
+// FormatTime formats t in canonical GPX form (UTC, RFC 3339).
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+/*==========================================================*/
+// NullableTime
+func (nt NullableTime) IsNull() bool {
+	return !nt.Valid
+}
+
+func (nt *NullableTime) SetValue(t time.Time) {
+	nt.Time = t
+	nt.Valid = true
+}
+
+func (nt *NullableTime) SetNull() {
+	nt.Time = time.Time{}
+	nt.Valid = false
+}
+
+func (nt NullableTime) String() string {
+	if !nt.Valid {
+		return ""
+	}
+	return FormatTime(nt.Time)
+}
+
+func (nt *NullableTime) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	value, err := decodeTrimmed(d, start)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		nt.SetNull()
+		return nil
+	}
+	t, err := ParseTime(value)
+	if err != nil {
+		return err
+	}
+	nt.SetValue(t)
+	return nil
+}
+
+func (nt NullableTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !nt.Valid {
+		return nil
+	}
+	return e.EncodeElement(FormatTime(nt.Time), start)
+}
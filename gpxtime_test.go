@@ -0,0 +1,62 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	cases := map[string]time.Time{
+		"2009-10-17T18:37:26Z":           time.Date(2009, 10, 17, 18, 37, 26, 0, time.UTC),
+		"2009-10-17T18:37:26.5Z":         time.Date(2009, 10, 17, 18, 37, 26, 500000000, time.UTC),
+		"2009-10-17T20:37:26+02:00":      time.Date(2009, 10, 17, 18, 37, 26, 0, time.UTC),
+		"2009-10-17T20:37:26+0200":       time.Date(2009, 10, 17, 18, 37, 26, 0, time.UTC),
+		"2008-02-26T19:49:13":            time.Date(2008, 2, 26, 19, 49, 13, 0, time.UTC),
+		" 2008-02-26T19:49:13.250 ":      time.Date(2008, 2, 26, 19, 49, 13, 250000000, time.UTC),
+		"2009-10-17T18:37:26.123456789Z": time.Date(2009, 10, 17, 18, 37, 26, 123456789, time.UTC),
+	}
+	for value, expected := range cases {
+		actual, err := ParseTime(value)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, expected.Equal(actual))
+	}
+
+	_, err := ParseTime("yesterday")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestParseTimeDefaultLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	DefaultLocation = loc
+	defer func() { DefaultLocation = time.UTC }()
+
+	actual, err := ParseTime("2016-01-23T05:56:41")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "2016-01-22T21:56:41Z", FormatTime(actual))
+}
+
+func TestNullableTimeXML(t *testing.T) {
+	var wp Wpt
+	err := xml.Unmarshal([]byte(`<wpt lat="1" lon="2"><time>2016-01-22T21:56:41</time></wpt>`), &wp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, wp.Time.Valid)
+	assert.Equal(t, `<Wpt lat="1" lon="2"><time>2016-01-22T21:56:41Z</time></Wpt>`, string(toXMLCompact(wp)))
+
+	wp.RemoveTime()
+	assert.Equal(t, true, wp.Time.IsNull())
+	assert.Equal(t, `<Wpt lat="1" lon="2"></Wpt>`, string(toXMLCompact(wp)))
+
+	// The zero instant is a valid time and must survive a round trip.
+	wp.Time.SetValue(time.Time{})
+	assert.Equal(t, `<Wpt lat="1" lon="2"><time>0001-01-01T00:00:00Z</time></Wpt>`, string(toXMLCompact(wp)))
+
+	err = xml.Unmarshal([]byte(`<wpt lat="1" lon="2"><time>not a time</time></wpt>`), &wp)
+	assert.NotEqual(t, nil, err)
+}
+
+func toXMLCompact(n interface{}) []byte {
+	content, _ := xml.Marshal(n)
+	return content
+}
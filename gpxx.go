@@ -0,0 +1,156 @@
+package gpxgo
+
+const (
+	GPXX_NAMESPACE = "http://www.garmin.com/xmlschemas/GpxExtensions/v3"
+	GPXX_PREFIX    = "gpxx"
+)
+
+// Display colors of GpxExtensions v3
+const (
+	GPXX_BLACK        = "Black"
+	GPXX_DARK_RED     = "DarkRed"
+	GPXX_DARK_GREEN   = "DarkGreen"
+	GPXX_DARK_YELLOW  = "DarkYellow"
+	GPXX_DARK_BLUE    = "DarkBlue"
+	GPXX_DARK_MAGENTA = "DarkMagenta"
+	GPXX_DARK_CYAN    = "DarkCyan"
+	GPXX_LIGHT_GRAY   = "LightGray"
+	GPXX_DARK_GRAY    = "DarkGray"
+	GPXX_RED          = "Red"
+	GPXX_GREEN        = "Green"
+	GPXX_YELLOW       = "Yellow"
+	GPXX_BLUE         = "Blue"
+	GPXX_MAGENTA      = "Magenta"
+	GPXX_CYAN         = "Cyan"
+	GPXX_WHITE        = "White"
+	GPXX_TRANSPARENT  = "Transparent"
+)
+
+// GpxxWaypointExtension is gpxx:WaypointExtension, found on waypoints.
+type GpxxWaypointExtension struct {
+	Proximity    NullableFloat64   `xml:"Proximity,omitempty"`
+	Temperature  NullableFloat64   `xml:"Temperature,omitempty"`
+	Depth        NullableFloat64   `xml:"Depth,omitempty"`
+	DisplayMode  string            `xml:"DisplayMode,omitempty"`
+	Categories   []string          `xml:"Categories>Category,omitempty"`
+	Address      *GpxxAddress      `xml:"Address,omitempty"`
+	PhoneNumbers []GpxxPhoneNumber `xml:"PhoneNumber,omitempty"`
+	Extensions   *Extensions       `xml:"Extensions,omitempty"`
+}
+
+type GpxxAddress struct {
+	StreetAddress []string `xml:"StreetAddress,omitempty"`
+	City          string   `xml:"City,omitempty"`
+	State         string   `xml:"State,omitempty"`
+	Country       string   `xml:"Country,omitempty"`
+	PostalCode    string   `xml:"PostalCode,omitempty"`
+}
+
+type GpxxPhoneNumber struct {
+	Category string `xml:"Category,attr,omitempty"`
+	Number   string `xml:",chardata"`
+}
+
+// GpxxRouteExtension is gpxx:RouteExtension, found on routes.
+type GpxxRouteExtension struct {
+	IsAutoNamed  bool        `xml:"IsAutoNamed"`
+	DisplayColor string      `xml:"DisplayColor,omitempty"`
+	Extensions   *Extensions `xml:"Extensions,omitempty"`
+}
+
+// GpxxRoutePointExtension is gpxx:RoutePointExtension, found on route points.
+// Points holds the gpxx:rpt shaping points of the leg to the next route point.
+type GpxxRoutePointExtension struct {
+	Subclass   string               `xml:"Subclass,omitempty"`
+	Points     []GpxxAutoroutePoint `xml:"rpt,omitempty"`
+	Extensions *Extensions          `xml:"Extensions,omitempty"`
+}
+
+type GpxxAutoroutePoint struct {
+	Lat      float64 `xml:"lat,attr"`
+	Lon      float64 `xml:"lon,attr"`
+	Subclass string  `xml:"Subclass,omitempty"`
+}
+
+// GpxxTrackExtension is gpxx:TrackExtension, found on tracks.
+type GpxxTrackExtension struct {
+	DisplayColor string      `xml:"DisplayColor,omitempty"`
+	Extensions   *Extensions `xml:"Extensions,omitempty"`
+}
+
+func init() {
+	RegisterExtension(GPXX_NAMESPACE, "WaypointExtension", GPXX_PREFIX, GpxxWaypointExtension{})
+	RegisterExtension(GPXX_NAMESPACE, "RouteExtension", GPXX_PREFIX, GpxxRouteExtension{})
+	RegisterExtension(GPXX_NAMESPACE, "RoutePointExtension", GPXX_PREFIX, GpxxRoutePointExtension{})
+	RegisterExtension(GPXX_NAMESPACE, "TrackExtension", GPXX_PREFIX, GpxxTrackExtension{})
+}
+
+/*==========================================================*/
+// Routes
+func (r *Rte) GpxxRouteExtension() (*GpxxRouteExtension, error) {
+	v, err := r.Extensions.Lookup(GPXX_NAMESPACE, "RouteExtension")
+	ext, _ := v.(*GpxxRouteExtension)
+	return ext, err
+}
+
+// SetGpxxRouteExtension replaces the gpxx:RouteExtension of the route, or
+// removes it if ext is nil.
+func (r *Rte) SetGpxxRouteExtension(ext *GpxxRouteExtension) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	r.Extensions = r.Extensions.Set(GPXX_NAMESPACE, "RouteExtension", v)
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) GpxxTrackExtension() (*GpxxTrackExtension, error) {
+	v, err := t.Extensions.Lookup(GPXX_NAMESPACE, "TrackExtension")
+	ext, _ := v.(*GpxxTrackExtension)
+	return ext, err
+}
+
+// SetGpxxTrackExtension replaces the gpxx:TrackExtension of the track, or
+// removes it if ext is nil.
+func (t *Trk) SetGpxxTrackExtension(ext *GpxxTrackExtension) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	t.Extensions = t.Extensions.Set(GPXX_NAMESPACE, "TrackExtension", v)
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) GpxxWaypointExtension() (*GpxxWaypointExtension, error) {
+	v, err := wp.Extensions.Lookup(GPXX_NAMESPACE, "WaypointExtension")
+	ext, _ := v.(*GpxxWaypointExtension)
+	return ext, err
+}
+
+// SetGpxxWaypointExtension replaces the gpxx:WaypointExtension of the
+// waypoint, or removes it if ext is nil.
+func (wp *Wpt) SetGpxxWaypointExtension(ext *GpxxWaypointExtension) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	wp.Extensions = wp.Extensions.Set(GPXX_NAMESPACE, "WaypointExtension", v)
+}
+
+func (wp *Wpt) GpxxRoutePointExtension() (*GpxxRoutePointExtension, error) {
+	v, err := wp.Extensions.Lookup(GPXX_NAMESPACE, "RoutePointExtension")
+	ext, _ := v.(*GpxxRoutePointExtension)
+	return ext, err
+}
+
+// SetGpxxRoutePointExtension replaces the gpxx:RoutePointExtension of the
+// route point, or removes it if ext is nil.
+func (wp *Wpt) SetGpxxRoutePointExtension(ext *GpxxRoutePointExtension) {
+	var v interface{}
+	if ext != nil {
+		v = ext
+	}
+	wp.Extensions = wp.Extensions.Set(GPXX_NAMESPACE, "RoutePointExtension", v)
+}
@@ -0,0 +1,77 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+func TestGpxxExtensions(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/gpxx_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	wext, err := gpx.Waypoints[0].GpxxWaypointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewNullableFloat64(25), wext.Proximity)
+	assert.Equal(t, "SymbolAndName", wext.DisplayMode)
+	assert.Equal(t, []string{"Work", "Favorites"}, wext.Categories)
+	assert.Equal(t, &GpxxAddress{StreetAddress: []string{"1200 Main St"}, City: "Seattle", State: "WA", PostalCode: "98101"}, wext.Address)
+	assert.Equal(t, []GpxxPhoneNumber{{Category: "Office", Number: "+1 206 555 0100"}}, wext.PhoneNumbers)
+
+	rext, err := gpx.Routes[0].GpxxRouteExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GPXX_MAGENTA, rext.DisplayColor)
+	assert.Equal(t, false, rext.IsAutoNamed)
+
+	rpext, err := gpx.Routes[0].Waypoints[0].GpxxRoutePointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(rpext.Points))
+	assert.Equal(t, GpxxAutoroutePoint{Lat: 47.646, Lon: -122.328, Subclass: "06000D1C0700A1010000"}, rpext.Points[1])
+	rpext, err = gpx.Routes[0].Waypoints[1].GpxxRoutePointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, (*GpxxRoutePointExtension)(nil), rpext)
+
+	text, err := gpx.Tracks[0].GpxxTrackExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, GPXX_DARK_RED, text.DisplayColor)
+}
+
+func TestGpxxRoundTrip(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/gpxx_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	reparsed, err := ParseWithContent(gpx.ToXML())
+	assert.Equal(t, nil, err)
+	rext, _ := gpx.Routes[0].GpxxRouteExtension()
+	rext2, _ := reparsed.Routes[0].GpxxRouteExtension()
+	assert.Equal(t, rext, rext2)
+	text, _ := gpx.Tracks[0].GpxxTrackExtension()
+	text2, _ := reparsed.Tracks[0].GpxxTrackExtension()
+	assert.Equal(t, text.DisplayColor, text2.DisplayColor)
+	ext, _ := gpx.Waypoints[0].GpxxWaypointExtension()
+	ext2, _ := reparsed.Waypoints[0].GpxxWaypointExtension()
+	assert.Equal(t, ext, ext2)
+
+	// Editing keeps the nested extensions.
+	text.DisplayColor = GPXX_BLUE
+	gpx.Tracks[0].SetGpxxTrackExtension(text)
+	info, _ := gpx.Tracks[0].Extensions.XML()
+	assert.Equal(t, true, strings.Contains(info, `<gpxx:TrackExtension xmlns:gpxx="`+GPXX_NAMESPACE+`"><gpxx:DisplayColor>Blue</gpxx:DisplayColor><gpxx:Extensions><vendor:note xmlns:vendor="urn:vendor">keep</vendor:note></gpxx:Extensions></gpxx:TrackExtension>`))
+
+	// New extensions declare the namespace.
+	wext := &GpxxWaypointExtension{Categories: []string{"Lake"}, PhoneNumbers: []GpxxPhoneNumber{{Number: "911"}}}
+	gpx.Routes[0].Waypoints[1].SetGpxxWaypointExtension(wext)
+	info, _ = gpx.Routes[0].Waypoints[1].Extensions.XML()
+	assert.Equal(t, `<gpxx:WaypointExtension xmlns:gpxx="http://www.garmin.com/xmlschemas/GpxExtensions/v3"><gpxx:Categories><gpxx:Category>Lake</gpxx:Category></gpxx:Categories><gpxx:PhoneNumber>911</gpxx:PhoneNumber></gpxx:WaypointExtension>`, info)
+
+	reparsed, err = ParseWithContent(gpx.ToXML())
+	assert.Equal(t, nil, err)
+	text, _ = reparsed.Tracks[0].GpxxTrackExtension()
+	assert.Equal(t, GPXX_BLUE, text.DisplayColor)
+	wext2, err := reparsed.Routes[0].Waypoints[1].GpxxWaypointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, wext.Categories, wext2.Categories)
+
+	gpx.Routes[0].SetGpxxRouteExtension(nil)
+	assert.Equal(t, (*Extensions)(nil), gpx.Routes[0].Extensions)
+}
@@ -0,0 +1,287 @@
+package gpxgo
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	KML_NAMESPACE    = "http://www.opengis.net/kml/2.2"
+	KML_GX_NAMESPACE = "http://www.google.com/kml/ext/2.2"
+)
+
+// KMLStyle is the look of the routes and tracks written to KML.
+type KMLStyle struct {
+	// Color of the lines, with its alpha; the Google Earth default if nil
+	Color color.Color
+	// Width of the lines in pixels; the Google Earth default if 0
+	Width float64
+}
+
+type kml struct {
+	XMLName  xml.Name    `xml:"kml"`
+	XMLNs    string      `xml:"xmlns,attr"`
+	XMLNsGx  string      `xml:"xmlns:gx,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name        string         `xml:"name,omitempty"`
+	Description string         `xml:"description,omitempty"`
+	Styles      []kmlStyle     `xml:"Style"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlStyle struct {
+	ID        string       `xml:"id,attr"`
+	LineStyle kmlLineStyle `xml:"LineStyle"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color,omitempty"`
+	Width float64 `xml:"width,omitempty"`
+}
+
+type kmlPlacemark struct {
+	Name          string            `xml:"name,omitempty"`
+	Description   string            `xml:"description,omitempty"`
+	TimeStamp     *kmlTimeStamp     `xml:"TimeStamp,omitempty"`
+	StyleURL      string            `xml:"styleUrl,omitempty"`
+	Point         *kmlPoint         `xml:"Point,omitempty"`
+	LineString    *kmlPoint         `xml:"LineString,omitempty"`
+	MultiTrack    *kmlMultiTrack    `xml:"gx:MultiTrack,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+// kmlPoint is a Point or a LineString.
+type kmlPoint struct {
+	AltitudeMode string `xml:"altitudeMode,omitempty"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type kmlMultiTrack struct {
+	AltitudeMode string     `xml:"altitudeMode"`
+	Interpolate  int        `xml:"gx:interpolate"`
+	Tracks       []kmlTrack `xml:"gx:Track"`
+}
+
+// kmlMultiGeometry holds the segments of a track with points without time,
+// in order.
+type kmlMultiGeometry struct {
+	Geometries []kmlGeometry
+}
+
+// kmlGeometry is a segment of a kmlMultiGeometry: a gx:Track if it is timed,
+// a LineString otherwise.
+type kmlGeometry struct {
+	Track      *kmlTrack
+	LineString *kmlPoint
+}
+
+// kmlTrack has one when per coord.
+type kmlTrack struct {
+	AltitudeMode string   `xml:"altitudeMode,omitempty"`
+	When         []string `xml:"when"`
+	Coords       []string `xml:"gx:coord"`
+}
+
+/*==========================================================*/
+// Static
+func formatKMLFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// kmlColor returns c as KML writes it: aabbggrr in hexadecimal.
+func kmlColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("%02x%02x%02x%02x", n.A, n.B, n.G, n.R)
+}
+
+// kmlAltitudeMode returns the altitude mode of points: absolute if they all
+// have an elevation.
+func kmlAltitudeMode(points Waypoints) string {
+	for i := range points {
+		if points[i].Ele.IsNull() {
+			return "clampToGround"
+		}
+	}
+	return "absolute"
+}
+
+func kmlDescription(desc, cmt string) string {
+	if desc != "" {
+		return desc
+	}
+	return cmt
+}
+
+// kmlTimed returns whether all points have a time.
+func kmlTimed(points Waypoints) bool {
+	for i := range points {
+		if points[i].Time.IsNull() {
+			return false
+		}
+	}
+	return true
+}
+
+// kmlLineString returns the points as a LineString.
+func kmlLineString(points Waypoints) kmlPoint {
+	coordinates := make([]string, len(points))
+	for i := range points {
+		coordinates[i] = points[i].kmlCoordinates()
+	}
+	return kmlPoint{AltitudeMode: kmlAltitudeMode(points), Coordinates: strings.Join(coordinates, " ")}
+}
+
+// kmlTrackOf returns the timed points as a gx:Track.
+func kmlTrackOf(points Waypoints) kmlTrack {
+	var track kmlTrack
+	for i := range points {
+		wp := &points[i]
+		track.When = append(track.When, FormatTime(wp.Time.Time))
+		track.Coords = append(track.Coords, fmt.Sprintf("%s %s %s", formatKMLFloat(wp.Lon), formatKMLFloat(wp.Lat), formatKMLFloat(wp.Ele.Float64)))
+	}
+	return track
+}
+
+/*==========================================================*/
+// kmlGeometry
+func (geometry kmlGeometry) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if geometry.Track != nil {
+		return e.EncodeElement(geometry.Track, xml.StartElement{Name: xml.Name{Local: "gx:Track"}})
+	}
+	return e.EncodeElement(geometry.LineString, xml.StartElement{Name: xml.Name{Local: "LineString"}})
+}
+
+/*==========================================================*/
+// Gpx
+
+// ToKML converts the document to KML 2.2, for Google Earth: waypoints are
+// Placemarks with a Point, routes Placemarks with a LineString and tracks
+// Placemarks with a gx:MultiTrack holding a gx:Track of timed coordinates
+// per segment. Segments with points without time are LineStrings instead,
+// the track then being a MultiGeometry. The lines use style, if not nil.
+func (g *Gpx) ToKML(style *KMLStyle) ([]byte, error) {
+	document := kml{XMLNs: KML_NAMESPACE, XMLNsGx: KML_GX_NAMESPACE}
+	doc := &document.Document
+	var styleURL string
+	if style != nil {
+		doc.Styles = []kmlStyle{{ID: "line", LineStyle: kmlLineStyle{Color: kmlColor(style.Color), Width: style.Width}}}
+		styleURL = "#line"
+	}
+	if g.Metadata != nil {
+		doc.Name = g.Metadata.Name
+		doc.Description = g.Metadata.Desc
+	}
+
+	for i := range g.Waypoints {
+		wp := &g.Waypoints[i]
+		placemark := kmlPlacemark{Name: wp.Name, Description: kmlDescription(wp.Desc, wp.Cmt)}
+		if !wp.Time.IsNull() {
+			placemark.TimeStamp = &kmlTimeStamp{When: FormatTime(wp.Time.Time)}
+		}
+		placemark.Point = &kmlPoint{Coordinates: wp.kmlCoordinates()}
+		if !wp.Ele.IsNull() {
+			placemark.Point.AltitudeMode = "absolute"
+		}
+		doc.Placemarks = append(doc.Placemarks, placemark)
+	}
+	for _, rte := range g.Routes {
+		lineString := kmlLineString(rte.Waypoints)
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        rte.Name,
+			Description: kmlDescription(rte.Desc, rte.Cmt),
+			StyleURL:    styleURL,
+			LineString:  &lineString,
+		})
+	}
+	for _, trk := range g.Tracks {
+		placemark := kmlPlacemark{
+			Name:        trk.Name,
+			Description: kmlDescription(trk.Desc, trk.Cmt),
+			StyleURL:    styleURL,
+		}
+		timed := true
+		for _, seg := range trk.Segments {
+			timed = timed && kmlTimed(seg.Waypoints)
+		}
+		if timed {
+			placemark.MultiTrack = &kmlMultiTrack{AltitudeMode: "absolute"}
+			for _, seg := range trk.Segments {
+				if kmlAltitudeMode(seg.Waypoints) != "absolute" {
+					placemark.MultiTrack.AltitudeMode = "clampToGround"
+				}
+				placemark.MultiTrack.Tracks = append(placemark.MultiTrack.Tracks, kmlTrackOf(seg.Waypoints))
+			}
+		} else {
+			placemark.MultiGeometry = new(kmlMultiGeometry)
+			for _, seg := range trk.Segments {
+				var geometry kmlGeometry
+				if kmlTimed(seg.Waypoints) {
+					track := kmlTrackOf(seg.Waypoints)
+					track.AltitudeMode = kmlAltitudeMode(seg.Waypoints)
+					geometry.Track = &track
+				} else {
+					lineString := kmlLineString(seg.Waypoints)
+					geometry.LineString = &lineString
+				}
+				placemark.MultiGeometry.Geometries = append(placemark.MultiGeometry.Geometries, geometry)
+			}
+		}
+		doc.Placemarks = append(doc.Placemarks, placemark)
+	}
+
+	content, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// WriteKML writes the document to w as ToKML.
+func (g *Gpx) WriteKML(w io.Writer, style *KMLStyle) error {
+	content, err := g.ToKML(style)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// WriteKMZ writes the document to w as ToKML, in a KMZ archive.
+func (g *Gpx) WriteKMZ(w io.Writer, style *KMLStyle) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.Create("doc.kml")
+	if err != nil {
+		return err
+	}
+	if err := g.WriteKML(f, style); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+/*==========================================================*/
+// Wpt
+
+// kmlCoordinates returns the position of wp as a KML tuple: lon,lat[,ele].
+func (wp *Wpt) kmlCoordinates() string {
+	coordinates := formatKMLFloat(wp.Lon) + "," + formatKMLFloat(wp.Lat)
+	if !wp.Ele.IsNull() {
+		coordinates += "," + formatKMLFloat(wp.Ele.Float64)
+	}
+	return coordinates
+}
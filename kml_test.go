@@ -0,0 +1,100 @@
+package gpxgo
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/bmizerany/assert"
+	"image/color"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToKML(t *testing.T) {
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	gpx := NewGpx()
+	gpx.Metadata = &Metadata{Name: "Report"}
+	gpx.Waypoints = Waypoints{{Lat: 45.5, Lon: 7.25, Ele: NewNullableFloat64(1200), Time: NewNullableTime(start), Name: "Hut", Cmt: "Closed in winter"}}
+	gpx.Routes = []Rte{{Name: "Ascent", Waypoints: Waypoints{{Lat: 45, Lon: 7}, {Lat: 45.1, Lon: 7.1}}}}
+	gpx.Tracks = []Trk{{Name: "Walk", Segments: []Trkseg{
+		{Waypoints: Waypoints{{Lat: 1, Lon: 2, Ele: NewNullableFloat64(3), Time: NewNullableTime(start)}, {Lat: 1.5, Lon: 2.5, Ele: NewNullableFloat64(4), Time: NewNullableTime(start.Add(time.Minute))}}},
+	}}, {Name: "Partly timed", Segments: []Trkseg{
+		{Waypoints: Waypoints{{Lat: 1, Lon: 2, Time: NewNullableTime(start)}, {Lat: 1.5, Lon: 2.5}}},
+		{Waypoints: Waypoints{{Lat: 2, Lon: 3, Ele: NewNullableFloat64(5), Time: NewNullableTime(start)}}},
+	}}}
+
+	kml, err := gpx.ToKML(&KMLStyle{Color: color.RGBA{R: 255, A: 255}, Width: 3})
+	assert.Equal(t, nil, err)
+	content := string(kml)
+	for _, expected := range []string{
+		`<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">`,
+		`<name>Report</name>`,
+		`<Style id="line">
+      <LineStyle>
+        <color>ff0000ff</color>
+        <width>3</width>
+      </LineStyle>
+    </Style>`,
+		`<Placemark>
+      <name>Hut</name>
+      <description>Closed in winter</description>
+      <TimeStamp>
+        <when>2016-06-29T10:00:00Z</when>
+      </TimeStamp>
+      <Point>
+        <altitudeMode>absolute</altitudeMode>
+        <coordinates>7.25,45.5,1200</coordinates>
+      </Point>
+    </Placemark>`,
+		`<LineString>
+        <altitudeMode>clampToGround</altitudeMode>
+        <coordinates>7,45 7.1,45.1</coordinates>
+      </LineString>`,
+		`<styleUrl>#line</styleUrl>
+      <gx:MultiTrack>
+        <altitudeMode>absolute</altitudeMode>
+        <gx:interpolate>0</gx:interpolate>
+        <gx:Track>
+          <when>2016-06-29T10:00:00Z</when>
+          <when>2016-06-29T10:01:00Z</when>
+          <gx:coord>2 1 3</gx:coord>
+          <gx:coord>2.5 1.5 4</gx:coord>
+        </gx:Track>
+      </gx:MultiTrack>`,
+		`<name>Partly timed</name>
+      <styleUrl>#line</styleUrl>
+      <MultiGeometry>
+        <LineString>
+          <altitudeMode>clampToGround</altitudeMode>
+          <coordinates>2,1 2.5,1.5</coordinates>
+        </LineString>
+        <gx:Track>
+          <altitudeMode>absolute</altitudeMode>
+          <when>2016-06-29T10:00:00Z</when>
+          <gx:coord>3 2 5</gx:coord>
+        </gx:Track>
+      </MultiGeometry>`,
+	} {
+		assert.Equal(t, true, strings.Contains(content, expected), expected)
+	}
+	assert.Equal(t, false, strings.Contains(content, "<when></when>"))
+	assert.Equal(t, false, strings.Contains(content, "<TimeStamp></TimeStamp>"))
+
+	// Without style
+	kml, err = gpx.ToKML(nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, strings.Contains(string(kml), "Style"))
+
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteKMZ(&buffer, nil))
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(archive.File))
+	assert.Equal(t, "doc.kml", archive.File[0].Name)
+	r, err := archive.File[0].Open()
+	assert.Equal(t, nil, err)
+	doc, err := ioutil.ReadAll(r)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, kml, doc)
+}
@@ -0,0 +1,171 @@
+package gpxgo
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// 1 km/h in m/s, the same default as gpxpy.
+	DEFAULT_STOPPED_SPEED_THRESHOLD = 1. / 3.6
+	DEFAULT_SPEED_PERCENTILE        = 0.05
+)
+
+// MovingData is the result of a moving/stopped analysis. Distances are in
+// meters, MaxSpeed in m/s.
+type MovingData struct {
+	MovingTime      time.Duration
+	StoppedTime     time.Duration
+	MovingDistance  float64
+	StoppedDistance float64
+	MaxSpeed        float64
+}
+
+// MovingDataOptions configures MovingDataWithOptions.
+//
+// Legs slower than StoppedSpeedThreshold (m/s) count as stopped. The fastest
+// SpeedPercentile of the moving legs is ignored when computing MaxSpeed, and
+// with IgnoreNonstandardDistances legs whose length is more than 1.5 standard
+// deviations off the mean are ignored too; both reject GPS jumps.
+type MovingDataOptions struct {
+	StoppedSpeedThreshold      float64
+	SpeedPercentile            float64
+	IgnoreNonstandardDistances bool
+}
+
+type speedAndDistance struct {
+	speed    float64
+	distance float64
+}
+
+/*==========================================================*/
+// Static
+func DefaultMovingDataOptions() MovingDataOptions {
+	return MovingDataOptions{
+		StoppedSpeedThreshold:      DEFAULT_STOPPED_SPEED_THRESHOLD,
+		SpeedPercentile:            DEFAULT_SPEED_PERCENTILE,
+		IgnoreNonstandardDistances: true,
+	}
+}
+
+func maxSpeed(speedsAndDistances []speedAndDistance, percentile float64, ignoreNonstandardDistances bool) float64 {
+	if len(speedsAndDistances) == 0 {
+		return 0.0
+	}
+
+	if ignoreNonstandardDistances {
+		var sum, variance float64
+		for _, sd := range speedsAndDistances {
+			sum += sd.distance
+		}
+		average := sum / float64(len(speedsAndDistances))
+		for _, sd := range speedsAndDistances {
+			variance += math.Pow(sd.distance-average, 2)
+		}
+		deviation := math.Sqrt(variance / float64(len(speedsAndDistances)))
+
+		var filtered []speedAndDistance
+		for _, sd := range speedsAndDistances {
+			if math.Abs(sd.distance-average) <= deviation*1.5 {
+				filtered = append(filtered, sd)
+			}
+		}
+		speedsAndDistances = filtered
+	}
+
+	speeds := make([]float64, 0, len(speedsAndDistances))
+	for _, sd := range speedsAndDistances {
+		speeds = append(speeds, sd.speed)
+	}
+	if len(speeds) == 0 {
+		return 0.0
+	}
+	sort.Float64s(speeds)
+
+	index := int(float64(len(speeds)) * (1 - percentile))
+	if index >= len(speeds) {
+		index = len(speeds) - 1
+	}
+	return speeds[index]
+}
+
+/*==========================================================*/
+// MovingData
+func (md *MovingData) merge(md2 MovingData) {
+	md.MovingTime += md2.MovingTime
+	md.StoppedTime += md2.StoppedTime
+	md.MovingDistance += md2.MovingDistance
+	md.StoppedDistance += md2.StoppedDistance
+	md.MaxSpeed = math.Max(md.MaxSpeed, md2.MaxSpeed)
+}
+
+/*==========================================================*/
+// Gpx
+func (g *Gpx) MovingData() MovingData {
+	return g.MovingDataWithOptions(DefaultMovingDataOptions())
+}
+
+func (g *Gpx) MovingDataWithOptions(opts MovingDataOptions) MovingData {
+	var md MovingData
+	for i := range g.Tracks {
+		md.merge(g.Tracks[i].MovingDataWithOptions(opts))
+	}
+	return md
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) MovingData() MovingData {
+	return t.MovingDataWithOptions(DefaultMovingDataOptions())
+}
+
+func (t *Trk) MovingDataWithOptions(opts MovingDataOptions) MovingData {
+	var md MovingData
+	for i := range t.Segments {
+		md.merge(t.Segments[i].MovingDataWithOptions(opts))
+	}
+	return md
+}
+
+/*==========================================================*/
+// Trkseg
+func (ts *Trkseg) MovingData() MovingData {
+	return ts.MovingDataWithOptions(DefaultMovingDataOptions())
+}
+
+func (ts *Trkseg) MovingDataWithOptions(opts MovingDataOptions) MovingData {
+	var (
+		md                 MovingData
+		speedsAndDistances []speedAndDistance
+	)
+	for i := 1; i < len(ts.Waypoints); i++ {
+		previous := &ts.Waypoints[i-1]
+		point := &ts.Waypoints[i]
+		if !previous.Time.Valid || !point.Time.Valid {
+			continue
+		}
+
+		duration := point.Time.Time.Sub(previous.Time.Time)
+		distance := point.Length3D(previous)
+		seconds := duration.Seconds()
+
+		speed := 0.0
+		if seconds > 0 {
+			speed = distance / seconds
+		}
+
+		if speed <= opts.StoppedSpeedThreshold {
+			md.StoppedTime += duration
+			md.StoppedDistance += distance
+		} else {
+			md.MovingTime += duration
+			md.MovingDistance += distance
+			if distance > 0 {
+				speedsAndDistances = append(speedsAndDistances, speedAndDistance{speed, distance})
+			}
+		}
+	}
+	md.MaxSpeed = maxSpeed(speedsAndDistances, opts.SpeedPercentile, opts.IgnoreNonstandardDistances)
+	return md
+}
@@ -0,0 +1,79 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func newTimedSegment(start time.Time, lats []float64, seconds []int) Trkseg {
+	seg := Trkseg{}
+	for i, lat := range lats {
+		wp := Wpt{Lat: lat, Lon: 10.0}
+		wp.Time.SetValue(start.Add(time.Duration(seconds[i]) * time.Second))
+		seg.Waypoints = append(seg.Waypoints, wp)
+	}
+	return seg
+}
+
+func TestMovingData(t *testing.T) {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	// Four moving legs of ~11 m in 2 s, then two stopped legs of 30 s.
+	seg := newTimedSegment(start,
+		[]float64{0.0, 0.0001, 0.0002, 0.0003, 0.0004, 0.0004, 0.0004},
+		[]int{0, 2, 4, 6, 8, 38, 68})
+
+	md := seg.MovingData()
+	assert.Equal(t, 8*time.Second, md.MovingTime)
+	assert.Equal(t, 60*time.Second, md.StoppedTime)
+	assert.Equal(t, true, math.Abs(md.MovingDistance-seg.Length2D()) < 0.000001)
+	assert.Equal(t, 0.0, md.StoppedDistance)
+	assert.Equal(t, true, math.Abs(md.MaxSpeed-seg.Waypoints[1].Length2D(&seg.Waypoints[0])/2) < 0.000001)
+
+	// Raising the threshold above the walking speed turns everything into stops.
+	opts := DefaultMovingDataOptions()
+	opts.StoppedSpeedThreshold = 10
+	md = seg.MovingDataWithOptions(opts)
+	assert.Equal(t, time.Duration(0), md.MovingTime)
+	assert.Equal(t, 68*time.Second, md.StoppedTime)
+	assert.Equal(t, 0.0, md.MaxSpeed)
+}
+
+func TestMovingDataOutliers(t *testing.T) {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	lats := []float64{}
+	seconds := []int{}
+	for i := 0; i < 20; i++ {
+		lats = append(lats, float64(i)*0.0001)
+		seconds = append(seconds, i)
+	}
+	// A GPS jump of ~1 km in one second.
+	lats = append(lats, lats[len(lats)-1]+0.01)
+	seconds = append(seconds, 20)
+	seg := newTimedSegment(start, lats, seconds)
+
+	md := seg.MovingData()
+	assert.Equal(t, true, md.MaxSpeed < 12)
+
+	opts := DefaultMovingDataOptions()
+	opts.IgnoreNonstandardDistances = false
+	opts.SpeedPercentile = 0
+	md = seg.MovingDataWithOptions(opts)
+	assert.Equal(t, true, md.MaxSpeed > 1000)
+}
+
+func TestMovingDataGpx(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	md := gpx.MovingData()
+	trkMd := gpx.Tracks[0].MovingData()
+	assert.Equal(t, trkMd, md)
+	assert.Equal(t, true, md.MovingTime > 0)
+	assert.Equal(t, true, math.Abs(md.MovingDistance+md.StoppedDistance-gpx.Length2D()) < 0.000001)
+
+	// Points without time are skipped.
+	gpx.RemoveTime()
+	assert.Equal(t, MovingData{}, gpx.MovingData())
+}
@@ -0,0 +1,498 @@
+package gpxgo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NMEAChecksumError is the cause of the ParseError of a NMEA sentence whose
+// checksum does not match its content.
+type NMEAChecksumError struct {
+	Expected byte
+	Actual   byte
+}
+
+// nmeaEpoch is a fix, read from the sentences of a time of day, or if lost
+// a loss of fix reported without time.
+type nmeaEpoch struct {
+	lost       bool
+	timeOfDay  time.Duration
+	date       time.Time
+	wp         Wpt
+	positioned bool
+	invalid    bool
+	quality    NullableInt
+	mode       string
+	used       NullableInt
+}
+
+// nmeaReader builds a Gpx from NMEA sentences.
+type nmeaReader struct {
+	epochs   []*nmeaEpoch
+	warnings []*ParseError
+}
+
+// A knot, in m/s
+const nmeaKnot = 1852.0 / 3600
+
+/*==========================================================*/
+// Static
+
+// ParseNMEAWithReader reads NMEA 0183 sentences, e.g. the serial output of a
+// data logger, as a track. GGA and RMC sentences of a time of day make a
+// point, with its fix, satellites, dilutions, DGPS data, geoid height and
+// magnetic variation, completed by the GSA and VTG sentences following them.
+// GSV sentences are checked but have no counterpart in GPX. Dates are those
+// of RMC sentences, carried across midnight; without any, points have no
+// time. A loss of fix ends the segment, as do the sentences without time of
+// a receiver that has no fix. Sentences of other types are ignored;
+// sentences whose checksum does not match (see NMEAChecksumError) or which
+// are malformed are skipped and recorded in Warnings.
+func ParseNMEAWithReader(r io.Reader) (*Gpx, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	var reader nmeaReader
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		reader.read(line, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return reader.finish(), nil
+}
+
+func ParseNMEAWithContent(content []byte) (*Gpx, error) {
+	return ParseNMEAWithReader(bytes.NewReader(content))
+}
+
+func nmeaFloat(value string) (NullableFloat64, error) {
+	if value == "" {
+		return NullableFloat64{}, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return NullableFloat64{}, err
+	}
+	return NewNullableFloat64(f), nil
+}
+
+func nmeaInt(value string) (NullableInt, error) {
+	if value == "" {
+		return NullableInt{}, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return NullableInt{}, err
+	}
+	return NewNullableInt(i), nil
+}
+
+// nmeaDegrees returns the degrees of a latitude (ddmm.mm) or longitude
+// (dddmm.mm), negative in the hemisphere negative.
+func nmeaDegrees(value, hemisphere, negative string) (NullableFloat64, error) {
+	if value == "" {
+		return NullableFloat64{}, nil
+	}
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		dot = len(value)
+	}
+	if dot < 2 {
+		return NullableFloat64{}, fmt.Errorf("invalid coordinate %q", value)
+	}
+	degrees, err := strconv.Atoi(value[:dot-2])
+	if err != nil && dot > 2 {
+		return NullableFloat64{}, err
+	}
+	minutes, err := strconv.ParseFloat(value[dot-2:], 64)
+	if err != nil {
+		return NullableFloat64{}, err
+	}
+	result := float64(degrees) + minutes/60
+	if hemisphere == negative {
+		result = -result
+	}
+	return NewNullableFloat64(result), nil
+}
+
+// nmeaTimeOfDay returns the time of hhmmss.ss.
+func nmeaTimeOfDay(value string) (time.Duration, error) {
+	if len(value) < 6 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	var total float64
+	for i, unit := range []time.Duration{time.Hour, time.Minute} {
+		n, err := strconv.Atoi(value[2*i : 2*i+2])
+		if err != nil {
+			return 0, err
+		}
+		total += float64(n) * float64(unit)
+	}
+	seconds, err := strconv.ParseFloat(value[4:], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(total + seconds*float64(time.Second)), nil
+}
+
+// nmeaDate returns the date of ddmmyy, of 1980 to 2079.
+func nmeaDate(value string) (time.Time, error) {
+	date, err := time.Parse("020106", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date.Year() >= 2080 {
+		date = date.AddDate(-100, 0, 0)
+	}
+	return date, nil
+}
+
+// nmeaSentence returns the address (e.g. GPGGA) and the fields of sentence,
+// checking its checksum if any.
+func nmeaSentence(sentence string) (string, []string, error) {
+	content := sentence[1:]
+	if star := strings.LastIndexByte(content, '*'); star >= 0 {
+		expected, err := strconv.ParseUint(strings.TrimSpace(content[star+1:]), 16, 8)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid checksum %q", content[star+1:])
+		}
+		content = content[:star]
+		var actual byte
+		for i := 0; i < len(content); i++ {
+			actual ^= content[i]
+		}
+		if actual != byte(expected) {
+			return "", nil, &NMEAChecksumError{Expected: byte(expected), Actual: actual}
+		}
+	}
+	fields := strings.Split(content, ",")
+	return fields[0], fields[1:], nil
+}
+
+/*==========================================================*/
+// NMEAChecksumError
+func (e *NMEAChecksumError) Error() string {
+	return fmt.Sprintf("NMEA checksum %02X, expected %02X", e.Actual, e.Expected)
+}
+
+/*==========================================================*/
+// nmeaReader
+func (r *nmeaReader) read(line int, text string) {
+	start := strings.IndexByte(text, '$')
+	if start < 0 {
+		return
+	}
+	address, fields, err := nmeaSentence(strings.TrimRight(text[start:], "\r\n "))
+	if err == nil && len(address) == 5 {
+		err = r.add(address[2:], fields)
+	}
+	if err != nil {
+		path := address
+		if path == "" {
+			path = strings.SplitN(text[start+1:], ",", 2)[0]
+		}
+		r.warnings = append(r.warnings, &ParseError{Line: line, Column: start + 1, Path: path, Err: err})
+	}
+}
+
+// epoch returns the epoch of the time of day, the current one or a new one.
+func (r *nmeaReader) epoch(value string) (*nmeaEpoch, error) {
+	timeOfDay, err := nmeaTimeOfDay(value)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(r.epochs); n > 0 && r.epochs[n-1].timeOfDay == timeOfDay {
+		return r.epochs[n-1], nil
+	}
+	epoch := &nmeaEpoch{timeOfDay: timeOfDay}
+	r.epochs = append(r.epochs, epoch)
+	return epoch, nil
+}
+
+// loseFix records a loss of fix without time, which also takes the sentences
+// without time following it.
+func (r *nmeaReader) loseFix() {
+	if n := len(r.epochs); n == 0 || !r.epochs[n-1].lost {
+		r.epochs = append(r.epochs, &nmeaEpoch{lost: true})
+	}
+}
+
+// add reads the fields of a sentence of type typ.
+func (r *nmeaReader) add(typ string, fields []string) error {
+	expected := map[string]int{"GGA": 14, "RMC": 11, "GSA": 17, "GSV": 3, "VTG": 8}[typ]
+	if expected == 0 {
+		return nil
+	}
+	if len(fields) < expected {
+		return fmt.Errorf("%d fields, expected at least %d", len(fields), expected)
+	}
+	switch typ {
+	case "GGA":
+		return r.addGGA(fields)
+	case "RMC":
+		return r.addRMC(fields)
+	case "GSV":
+		for _, field := range fields[:3] {
+			if _, err := nmeaInt(field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Without time, of the current epoch
+	epoch := &nmeaEpoch{}
+	if n := len(r.epochs); n > 0 {
+		epoch = r.epochs[n-1]
+	}
+	if typ == "GSA" {
+		return epoch.addGSA(fields)
+	}
+	return epoch.addVTG(fields)
+}
+
+func (r *nmeaReader) addGGA(fields []string) error {
+	lat, err := nmeaDegrees(fields[1], fields[2], "S")
+	if err != nil {
+		return err
+	}
+	lon, err := nmeaDegrees(fields[3], fields[4], "W")
+	if err != nil {
+		return err
+	}
+	quality, err := nmeaInt(fields[5])
+	if err != nil {
+		return err
+	}
+	var values [4]NullableFloat64
+	for i, field := range []string{fields[7], fields[8], fields[10], fields[12]} {
+		if values[i], err = nmeaFloat(field); err != nil {
+			return err
+		}
+	}
+	sat, err := nmeaInt(fields[6])
+	if err != nil {
+		return err
+	}
+	dgpsid, err := nmeaInt(fields[13])
+	if err != nil {
+		return err
+	}
+	if fields[0] == "" && quality.Valid && quality.Int == 0 {
+		r.loseFix()
+		return nil
+	}
+	epoch, err := r.epoch(fields[0])
+	if err != nil {
+		return err
+	}
+
+	epoch.setPosition(lat, lon)
+	epoch.quality = quality
+	epoch.invalid = epoch.invalid || (quality.Valid && quality.Int == 0)
+	epoch.wp.Sat = sat
+	epoch.wp.Hdop = values[0]
+	epoch.wp.Ele = values[1]
+	if values[2].Valid {
+		epoch.wp.Geoidheight = fields[10]
+	}
+	epoch.wp.Ageofdgpsdata = values[3]
+	epoch.wp.Dgpsid = dgpsid.Int
+	return nil
+}
+
+func (r *nmeaReader) addRMC(fields []string) error {
+	lat, err := nmeaDegrees(fields[2], fields[3], "S")
+	if err != nil {
+		return err
+	}
+	lon, err := nmeaDegrees(fields[4], fields[5], "W")
+	if err != nil {
+		return err
+	}
+	var values [3]NullableFloat64
+	for i, field := range []string{fields[6], fields[7], fields[9]} {
+		if values[i], err = nmeaFloat(field); err != nil {
+			return err
+		}
+	}
+	var date time.Time
+	if fields[8] != "" {
+		if date, err = nmeaDate(fields[8]); err != nil {
+			return err
+		}
+	}
+	if fields[0] == "" && fields[1] == "V" {
+		r.loseFix()
+		return nil
+	}
+	epoch, err := r.epoch(fields[0])
+	if err != nil {
+		return err
+	}
+
+	epoch.setPosition(lat, lon)
+	epoch.invalid = epoch.invalid || fields[1] == "V"
+	epoch.date = date
+	if values[0].Valid && !epoch.wp.Speed.Valid {
+		epoch.wp.Speed.SetValue(values[0].Float64 * nmeaKnot)
+	}
+	if !epoch.wp.Course.Valid {
+		epoch.wp.Course = values[1]
+	}
+	if magvar := values[2]; magvar.Valid {
+		// In degrees east, from 0 to 360
+		if fields[10] == "W" {
+			magvar.Float64 = math.Mod(360-magvar.Float64, 360)
+		}
+		epoch.wp.Magvar = strconv.FormatFloat(magvar.Float64, 'f', -1, 64)
+	}
+	return nil
+}
+
+// resolveDates gives the epochs without date the date of the previous one,
+// or before the first date the next one, changing at midnight.
+func (r *nmeaReader) resolveDates() {
+	var epochs []*nmeaEpoch
+	for _, epoch := range r.epochs {
+		if !epoch.lost {
+			epochs = append(epochs, epoch)
+		}
+	}
+	first := -1
+	for i, epoch := range epochs {
+		if !epoch.date.IsZero() {
+			if first < 0 {
+				first = i
+			}
+			continue
+		}
+		if first >= 0 {
+			previous := epochs[i-1]
+			epoch.date = previous.date
+			if epoch.timeOfDay < previous.timeOfDay {
+				epoch.date = epoch.date.AddDate(0, 0, 1)
+			}
+		}
+	}
+	for i := first - 1; i >= 0; i-- {
+		next := epochs[i+1]
+		epochs[i].date = next.date
+		if epochs[i].timeOfDay > next.timeOfDay {
+			epochs[i].date = next.date.AddDate(0, 0, -1)
+		}
+	}
+}
+
+func (r *nmeaReader) finish() *Gpx {
+	r.resolveDates()
+	gpx := NewGpx()
+	gpx.Warnings = r.warnings
+
+	var trk Trk
+	var seg Trkseg
+	for _, epoch := range r.epochs {
+		if !epoch.positioned || epoch.invalid || (!epoch.quality.Valid && epoch.mode == "1") {
+			if len(seg.Waypoints) > 0 {
+				trk.Segments = append(trk.Segments, seg)
+			}
+			seg = Trkseg{}
+			continue
+		}
+		wp := epoch.wp
+		wp.Fix = epoch.fix()
+		if !wp.Sat.Valid {
+			wp.Sat = epoch.used
+		}
+		if !epoch.date.IsZero() {
+			wp.Time = NewNullableTime(epoch.date.Add(epoch.timeOfDay))
+		}
+		seg.Waypoints = append(seg.Waypoints, wp)
+	}
+	if len(seg.Waypoints) > 0 {
+		trk.Segments = append(trk.Segments, seg)
+	}
+	if len(trk.Segments) > 0 {
+		gpx.Tracks = append(gpx.Tracks, trk)
+	}
+	return gpx
+}
+
+/*==========================================================*/
+// nmeaEpoch
+func (epoch *nmeaEpoch) setPosition(lat, lon NullableFloat64) {
+	if lat.Valid && lon.Valid {
+		epoch.wp.Lat, epoch.wp.Lon = lat.Float64, lon.Float64
+		epoch.positioned = true
+	}
+}
+
+func (epoch *nmeaEpoch) addGSA(fields []string) error {
+	var used int
+	for _, field := range fields[2:14] {
+		if field != "" {
+			used++
+		}
+	}
+	var dops [3]NullableFloat64
+	for i, field := range fields[14:17] {
+		var err error
+		if dops[i], err = nmeaFloat(strings.TrimSpace(field)); err != nil {
+			return err
+		}
+	}
+	epoch.mode = fields[1]
+	epoch.used = NewNullableInt(used)
+	epoch.wp.Pdop = dops[0]
+	if !epoch.wp.Hdop.Valid {
+		epoch.wp.Hdop = dops[1]
+	}
+	epoch.wp.Vdop = dops[2]
+	return nil
+}
+
+func (epoch *nmeaEpoch) addVTG(fields []string) error {
+	course, err := nmeaFloat(fields[0])
+	if err != nil {
+		return err
+	}
+	speed, err := nmeaFloat(fields[6])
+	if err != nil {
+		return err
+	}
+	if course.Valid {
+		epoch.wp.Course = course
+	}
+	if speed.Valid {
+		epoch.wp.Speed.SetValue(speed.Float64 / 3.6)
+	}
+	return nil
+}
+
+// fix returns the GPX fix of the GGA quality and GSA mode.
+func (epoch *nmeaEpoch) fix() string {
+	modes := map[string]string{"1": "none", "2": "2d", "3": "3d"}
+	if !epoch.quality.Valid {
+		return modes[epoch.mode]
+	}
+	switch epoch.quality.Int {
+	case 0:
+		return "none"
+	case 1:
+		return modes[epoch.mode]
+	case 2, 4, 5:
+		return "dgps"
+	case 3:
+		return "pps"
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package gpxgo
+
+import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"os"
+	"testing"
+)
+
+func TestParseNMEA(t *testing.T) {
+	f, err := os.Open("testdata/logger_sample.nmea")
+	assert.Equal(t, nil, err)
+	defer f.Close()
+	gpx, err := ParseNMEAWithReader(f)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, 1, len(gpx.Tracks))
+	// The loss of fix ends the segment.
+	segments := gpx.Tracks[0].Segments
+	assert.Equal(t, 2, len(segments))
+	assert.Equal(t, 3, len(segments[0].Waypoints))
+	assert.Equal(t, 1, len(segments[1].Waypoints))
+
+	wp := segments[0].Waypoints[0]
+	assert.Equal(t, 46.0, wp.Lat)
+	assert.Equal(t, 13.5, wp.Lon)
+	assert.Equal(t, "2016-06-28T23:59:58Z", wp.Time.String())
+	assert.Equal(t, NewNullableFloat64(120.4), wp.Ele)
+	assert.Equal(t, "dgps", wp.Fix)
+	assert.Equal(t, NewNullableInt(9), wp.Sat)
+	assert.Equal(t, NewNullableFloat64(0.9), wp.Hdop)
+	assert.Equal(t, NewNullableFloat64(2.1), wp.Vdop)
+	assert.Equal(t, NewNullableFloat64(2.5), wp.Pdop)
+	assert.Equal(t, NewNullableFloat64(3.5), wp.Ageofdgpsdata)
+	assert.Equal(t, 123, wp.Dgpsid)
+	assert.Equal(t, "46.9", wp.Geoidheight)
+	assert.Equal(t, "356.9", wp.Magvar)
+	assert.Equal(t, NewNullableFloat64(84.4), wp.Course)
+	assert.Equal(t, true, wp.Speed.Float64 > 4.166 && wp.Speed.Float64 < 4.167)
+
+	// Across midnight
+	wp = segments[0].Waypoints[1]
+	assert.Equal(t, "2016-06-29T00:00:00Z", wp.Time.String())
+	assert.Equal(t, "2d", wp.Fix)
+	assert.Equal(t, NewNullableFloat64(1.1), wp.Hdop)
+	wp = segments[0].Waypoints[2]
+	assert.Equal(t, "2016-06-29T00:00:01Z", wp.Time.String())
+	assert.Equal(t, -46.002, wp.Lat)
+	assert.Equal(t, -13.5, wp.Lon)
+	assert.Equal(t, "2016-06-29T00:00:05Z", segments[1].Waypoints[0].Time.String())
+
+	assert.Equal(t, 2, len(gpx.Warnings))
+	assert.Equal(t, "gpxgo: line 12, column 1, GPGGA: NMEA checksum 68, expected 3D", gpx.Warnings[0].Error())
+	var checksumErr *NMEAChecksumError
+	assert.Equal(t, true, errors.As(gpx.Warnings[0], &checksumErr))
+	assert.Equal(t, 14, gpx.Warnings[1].Line)
+	assert.Equal(t, "GPGGA", gpx.Warnings[1].Path)
+}
+
+func TestParseNMEADates(t *testing.T) {
+	// Before the first date, without checksums
+	gpx, err := ParseNMEAWithContent([]byte(`junk $GPGGA,235959,4600.0000,N,01330.0000,E,1,,1.0,,,,,,
+$GPGSA,A,3,04,05,09,12,24,,,,,,,,2.0,1.0,1.7
+$GPRMC,000000,A,4600.0010,N,01330.0000,E,,,290616,,
+`))
+	assert.Equal(t, nil, err)
+	points := gpx.Tracks[0].Segments[0].Waypoints
+	assert.Equal(t, 2, len(points))
+	assert.Equal(t, "2016-06-28T23:59:59Z", points[0].Time.String())
+	assert.Equal(t, "3d", points[0].Fix)
+	// From the satellites of GSA
+	assert.Equal(t, NewNullableInt(5), points[0].Sat)
+	assert.Equal(t, "2016-06-29T00:00:00Z", points[1].Time.String())
+	assert.Equal(t, false, points[1].Sat.Valid)
+	assert.Equal(t, 0, len(gpx.Warnings))
+
+	// Without date
+	gpx, err = ParseNMEAWithContent([]byte("$GPGGA,120000,4600.0000,N,01330.0000,E,1,05,1.0,,,,,,\n"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, gpx.Tracks[0].Segments[0].Waypoints[0].Time.Valid)
+
+	gpx, err = ParseNMEAWithContent([]byte("$GPRMC,120000,A,4600.0000,N\n"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(gpx.Tracks))
+	assert.Equal(t, "gpxgo: line 1, column 1, GPRMC: 4 fields, expected at least 11", gpx.Warnings[0].Error())
+}
+
+func TestParseNMEAColdStart(t *testing.T) {
+	gpx, err := ParseNMEAWithContent([]byte(`$GPRMC,120000,A,4600.0000,N,01330.0000,E,,,290616,0.0,W
+$GPGGA,,,,,,0,00,99.99,,,,,,*48
+$GPRMC,,V,,,,,,,,,,N*53
+$GPGSA,A,1,,,,,,,,,,,,,99.99,99.99,99.99
+$GPRMC,120010,A,4600.0010,N,01330.0000,E,,,,,
+`))
+	assert.Equal(t, nil, err)
+	// A loss of fix, not malformed sentences
+	assert.Equal(t, 0, len(gpx.Warnings))
+	segments := gpx.Tracks[0].Segments
+	assert.Equal(t, 2, len(segments))
+	assert.Equal(t, "0", segments[0].Waypoints[0].Magvar)
+	assert.Equal(t, "2016-06-29T12:00:10Z", segments[1].Waypoints[0].Time.String())
+	assert.Equal(t, false, segments[1].Waypoints[0].Pdop.Valid)
+}
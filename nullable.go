@@ -0,0 +1,142 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// NullableFloat64 is an optional numeric element. Valid is false when the
+// element is missing, so 0 can be told apart from "no value".
+type NullableFloat64 struct {
+	Float64 float64
+	Valid   bool
+}
+
+// NullableInt is the integer counterpart of NullableFloat64.
+type NullableInt struct {
+	Int   int
+	Valid bool
+}
+
+/*==========================================================*/
+// Static
+func NewNullableFloat64(f float64) NullableFloat64 {
+	return NullableFloat64{Float64: f, Valid: true}
+}
+
+func NewNullableInt(i int) NullableInt {
+	return NullableInt{Int: i, Valid: true}
+}
+
+func decodeTrimmed(d *xml.Decoder, start xml.StartElement) (string, error) {
+	var value string
+	if err := d.DecodeElement(&value, &start); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+/*==========================================================*/
+// NullableFloat64
+func (nf NullableFloat64) IsNull() bool {
+	return !nf.Valid
+}
+
+func (nf *NullableFloat64) SetValue(f float64) {
+	nf.Float64 = f
+	nf.Valid = true
+}
+
+// interpolate makes a registered NullableFloat64, e.g. DistanceMeters, an
+// interpolator.
+func (nf *NullableFloat64) interpolate(v, v2 interface{}, fraction float64) {
+	nf1, ok := v.(*NullableFloat64)
+	nf2, ok2 := v2.(*NullableFloat64)
+	if ok && ok2 {
+		interpolateFloat(nf, *nf1, *nf2, fraction)
+	}
+}
+
+func (nf *NullableFloat64) SetNull() {
+	nf.Float64 = 0.0
+	nf.Valid = false
+}
+
+func (nf NullableFloat64) String() string {
+	if !nf.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(nf.Float64, 'g', -1, 64)
+}
+
+func (nf *NullableFloat64) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	value, err := decodeTrimmed(d, start)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		nf.SetNull()
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	nf.SetValue(f)
+	return nil
+}
+
+func (nf NullableFloat64) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !nf.Valid {
+		return nil
+	}
+	return e.EncodeElement(nf.Float64, start)
+}
+
+/*==========================================================*/
+// NullableInt
+func (ni NullableInt) IsNull() bool {
+	return !ni.Valid
+}
+
+func (ni *NullableInt) SetValue(i int) {
+	ni.Int = i
+	ni.Valid = true
+}
+
+func (ni *NullableInt) SetNull() {
+	ni.Int = 0
+	ni.Valid = false
+}
+
+func (ni NullableInt) String() string {
+	if !ni.Valid {
+		return ""
+	}
+	return strconv.Itoa(ni.Int)
+}
+
+func (ni *NullableInt) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	value, err := decodeTrimmed(d, start)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		ni.SetNull()
+		return nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	ni.SetValue(i)
+	return nil
+}
+
+func (ni NullableInt) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !ni.Valid {
+		return nil
+	}
+	return e.EncodeElement(ni.Int, start)
+}
@@ -0,0 +1,115 @@
+package gpxgo
+
+import (
+	"math"
+	"time"
+)
+
+// PointData holds values derived for one point of a Trkseg, relative to the
+// previous point and to the start of the segment. Distances are 2D meters.
+//
+// Speed (m/s) and Pace (per kilometre) need point times, Grade (percent)
+// needs elevations; they are null/zero when the data is missing. Elapsed
+// durations only count legs where both points have a time.
+type PointData struct {
+	Point         *Wpt
+	Index         int
+	Distance      float64
+	TotalDistance float64
+	Elapsed       time.Duration
+	TotalElapsed  time.Duration
+	Bearing       float64
+	Speed         NullableFloat64
+	Pace          time.Duration
+	Grade         NullableFloat64
+}
+
+/*==========================================================*/
+// Static
+
+// NormalizeBearing maps a bearing from Bearing into [0, 360).
+func NormalizeBearing(bearing float64) float64 {
+	bearing = math.Mod(bearing, 360)
+	if bearing < 0 {
+		bearing += 360
+	}
+	return bearing
+}
+
+// Pace returns the time needed for one kilometre at speed m/s, or 0 if the
+// speed is not positive.
+func Pace(speed float64) time.Duration {
+	if speed <= 0 {
+		return 0
+	}
+	return time.Duration(1000. / speed * float64(time.Second))
+}
+
+/*==========================================================*/
+// Trkseg
+
+// PointsData returns the derived data of every point. Speed is averaged over
+// the speedWindow legs centered on each point; a window of 1 or less gives the
+// raw speed of the leg ending at the point.
+func (ts *Trkseg) PointsData(speedWindow int) []PointData {
+	n := len(ts.Waypoints)
+	if n == 0 {
+		return nil
+	}
+	if speedWindow < 1 {
+		speedWindow = 1
+	}
+
+	data := make([]PointData, n)
+	data[0] = PointData{Point: &ts.Waypoints[0], Index: 0}
+	for i := 1; i < n; i++ {
+		previous := &ts.Waypoints[i-1]
+		point := &ts.Waypoints[i]
+		pd := PointData{Point: point, Index: i}
+
+		pd.Distance = point.Length2D(previous)
+		pd.TotalDistance = data[i-1].TotalDistance + pd.Distance
+		if previous.Time.Valid && point.Time.Valid {
+			pd.Elapsed = point.Time.Time.Sub(previous.Time.Time)
+		}
+		pd.TotalElapsed = data[i-1].TotalElapsed + pd.Elapsed
+		pd.Bearing = NormalizeBearing(Bearing(previous.Lat, previous.Lon, point.Lat, point.Lon))
+		if previous.Ele.Valid && point.Ele.Valid && pd.Distance > 0 {
+			pd.Grade.SetValue((point.Ele.Float64 - previous.Ele.Float64) / pd.Distance * 100)
+		}
+		data[i] = pd
+	}
+
+	for i := 1; i < n; i++ {
+		var (
+			distance float64
+			elapsed  time.Duration
+			timed    bool
+		)
+		from := i - (speedWindow-1)/2
+		to := i + speedWindow/2
+		for j := from; j <= to; j++ {
+			if j < 1 || j >= n {
+				continue
+			}
+			if ts.Waypoints[j-1].Time.Valid && ts.Waypoints[j].Time.Valid {
+				distance += data[j].Distance
+				elapsed += data[j].Elapsed
+				timed = true
+			}
+		}
+		switch {
+		case !timed:
+			continue
+		case elapsed > 0:
+			data[i].Speed.SetValue(distance / elapsed.Seconds())
+		case distance == 0:
+			data[i].Speed.SetValue(0)
+		default:
+			// Same timestamp on distinct points, the speed is undefined.
+			continue
+		}
+		data[i].Pace = Pace(data[i].Speed.Float64)
+	}
+	return data
+}
@@ -0,0 +1,62 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPointsData(t *testing.T) {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	seg := newTimedSegment(start,
+		[]float64{0.0, 0.0001, 0.0002, 0.0004, 0.0004},
+		[]int{0, 2, 4, 6, 16})
+	seg.Waypoints[0].Ele.SetValue(100)
+	seg.Waypoints[1].Ele.SetValue(101)
+
+	data := seg.PointsData(1)
+	assert.Equal(t, 5, len(data))
+	assert.Equal(t, &seg.Waypoints[2], data[2].Point)
+
+	assert.Equal(t, true, data[0].Speed.IsNull())
+	assert.Equal(t, 0.0, data[0].TotalDistance)
+
+	leg := seg.Waypoints[1].Length2D(&seg.Waypoints[0])
+	assert.Equal(t, leg, data[1].Distance)
+	assert.Equal(t, 2*time.Second, data[1].Elapsed)
+	assert.Equal(t, true, math.Abs(data[1].Speed.Float64-leg/2) < 0.000001)
+	assert.Equal(t, Pace(leg/2), data[1].Pace)
+	assert.Equal(t, true, math.Abs(data[1].Bearing) < 0.000001)
+	assert.Equal(t, true, math.Abs(data[1].Grade.Float64-100/leg) < 0.000001)
+	assert.Equal(t, true, data[2].Grade.IsNull())
+
+	assert.Equal(t, NewNullableFloat64(0), data[4].Speed)
+	assert.Equal(t, time.Duration(0), data[4].Pace)
+	assert.Equal(t, 16*time.Second, data[4].TotalElapsed)
+	assert.Equal(t, true, math.Abs(data[4].TotalDistance-seg.Length2D()) < 0.000001)
+
+	// Centered window of three legs around point 2 covers points 1..3.
+	smoothed := seg.PointsData(3)
+	expected := seg.Waypoints[3].Length2D(&seg.Waypoints[0]) / 6
+	assert.Equal(t, true, math.Abs(smoothed[2].Speed.Float64-expected) < 0.001)
+}
+
+func TestPointsDataWithoutTime(t *testing.T) {
+	seg := Trkseg{}
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 1, Lon: 1})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 1, Lon: 1.001})
+
+	data := seg.PointsData(5)
+	assert.Equal(t, true, data[1].Speed.IsNull())
+	assert.Equal(t, time.Duration(0), data[1].TotalElapsed)
+	assert.Equal(t, true, math.Abs(data[1].Bearing-90) < 0.01)
+
+	assert.Equal(t, 0, len((&Trkseg{}).PointsData(1)))
+}
+
+func TestNormalizeBearing(t *testing.T) {
+	assert.Equal(t, 270.0, NormalizeBearing(-90))
+	assert.Equal(t, 0.0, NormalizeBearing(360))
+	assert.Equal(t, 45.0, NormalizeBearing(45))
+}
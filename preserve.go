@@ -0,0 +1,625 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ParseOptions changes how documents are parsed.
+type ParseOptions struct {
+	// Preserve keeps what gpxgo has no field for: the namespace declarations
+	// and other attributes of <gpx>, the original xsi:schemaLocation (or its
+	// absence) and the unknown elements (Unknown fields) with their position,
+	// so that ToXML writes back an equivalent document, with the prefixes of
+	// the document. Without it they are dropped, and the document is written
+	// with the default namespaces.
+	Preserve bool
+
+	// Lenient skips the points (waypoints, route and track points) that cannot
+	// be decoded, e.g. with a malformed coordinate, recording each one in
+	// Gpx.Warnings (Decoder.Warnings when streaming) instead of failing. XML
+	// syntax errors, e.g. of a truncated document, still fail.
+	Lenient bool
+}
+
+// the extensions and unknown elements of an element of the document
+type extensionsVisitor func(ext *Extensions, unknown *[]Extension)
+
+// unknownEncoder writes the unknown elements of an element at their position
+// among the known child elements written so far.
+type unknownEncoder struct {
+	known   int
+	unknown []Extension
+}
+
+// The fields of the types decoded by decodeElement, see elementFields
+var elementFieldsCache sync.Map
+
+/*==========================================================*/
+// Static
+
+// qualifyAttrs returns attrs, as decoded with namespaces, with their
+// qualified name in Name.Local, using the prefixes declared in attrs.
+func qualifyAttrs(attrs []xml.Attr) []xml.Attr {
+	prefixes := map[string]string{XML_NAMESPACE: "xml"}
+	for _, attr := range attrs {
+		if attr.Name.Space == "xmlns" {
+			prefixes[attr.Value] = attr.Name.Local
+		}
+	}
+
+	var qualified []xml.Attr
+	for _, attr := range attrs {
+		name := attr.Name
+		switch {
+		case name.Space == "":
+		case name.Space == "xmlns":
+			name = xml.Name{Local: "xmlns:" + name.Local}
+		case prefixes[name.Space] != "":
+			name = xml.Name{Local: prefixes[name.Space] + ":" + name.Local}
+		default:
+			// A prefix the document never declared
+			name = xml.Name{Local: name.Space + ":" + name.Local}
+		}
+		qualified = append(qualified, xml.Attr{Name: name, Value: attr.Value})
+	}
+	return qualified
+}
+
+// declaredPrefixes returns the prefixes of the namespaces declared by
+// qualified attrs.
+func declaredPrefixes(attrs []xml.Attr) map[string]string {
+	prefixes := map[string]string{}
+	for _, attr := range attrs {
+		if strings.HasPrefix(attr.Name.Local, "xmlns:") {
+			prefixes[attr.Value] = strings.TrimPrefix(attr.Name.Local, "xmlns:")
+		}
+	}
+	return prefixes
+}
+
+func discardUnknown(ext *Extensions, unknown *[]Extension) {
+	*unknown = nil
+}
+
+// preferPrefixes makes elements use the prefixes of the document.
+func preferPrefixes(prefixes map[string]string) extensionsVisitor {
+	prefer := func(items []Extension) {
+		for i := range items {
+			items[i].prefixes = prefixes
+		}
+	}
+	return func(ext *Extensions, unknown *[]Extension) {
+		if ext != nil {
+			prefer(ext.Items)
+		}
+		prefer(*unknown)
+	}
+}
+
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// rawStart returns a start element read by RawToken with its qualified names
+// in Name.Local, to be written as is.
+func rawStart(t xml.StartElement) xml.StartElement {
+	start := xml.StartElement{Name: xml.Name{Local: rawName(t.Name)}}
+	for _, attr := range t.Attr {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: rawName(attr.Name)}, Value: attr.Value})
+	}
+	return start
+}
+
+// encodeRaw writes self-contained XML through e, element by element. The
+// content of each element is written as is, keeping its white space.
+func encodeRaw(e *xml.Encoder, content string) error {
+	var (
+		start xml.StartElement
+		inner int64
+		depth int
+	)
+	d := xml.NewDecoder(strings.NewReader(content))
+	for {
+		offset := d.InputOffset()
+		token, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				start, inner = rawStart(t), d.InputOffset()
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				err = e.EncodeElement(struct {
+					Content string `xml:",innerxml"`
+				}{content[inner:offset]}, start)
+			}
+		case xml.CharData, xml.Comment:
+			if depth == 0 {
+				err = e.EncodeToken(token)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// elementFields returns the index of the fields of the struct type t by the
+// name of their child element, with "" for the field of unknown elements.
+func elementFields(t reflect.Type) map[string]int {
+	if fields, found := elementFieldsCache.Load(t); found {
+		return fields.(map[string]int)
+	}
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		switch {
+		case tag == ",any":
+			fields[""] = i
+		case field.Name == "XMLName" || tag == "" || tag == "-" || strings.Contains(tag, ",attr"):
+		default:
+			fields[strings.Split(tag, ",")[0]] = i
+		}
+	}
+	elementFieldsCache.Store(t, fields)
+	return fields
+}
+
+// decodeElement decodes the element started by start into v, a pointer to a
+// struct without UnmarshalXML, as DecodeElement would, recording the
+// position of its unknown elements.
+func decodeElement(d *xml.Decoder, start xml.StartElement, v interface{}) error {
+	// The attributes, from the start element alone
+	reader := tokenSlice{start, start.End()}
+	if err := xml.NewTokenDecoder(&reader).Decode(v); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	fields := elementFields(rv.Type())
+	known := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			i, found := fields[t.Name.Local]
+			if !found {
+				var item Extension
+				if err := d.DecodeElement(&item, &t); err != nil {
+					return err
+				}
+				item.position = known + 1
+				unknown := rv.Field(fields[""])
+				unknown.Set(reflect.Append(unknown, reflect.ValueOf(item)))
+				continue
+			}
+			known++
+			field := rv.Field(i)
+			if field.Kind() == reflect.Slice {
+				field.Set(reflect.Append(field, reflect.Zero(field.Type().Elem())))
+				field = field.Index(field.Len() - 1)
+			}
+			if err := d.DecodeElement(field.Addr().Interface(), &t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// encodeElement writes v, a pointer to a struct without MarshalXML, as
+// EncodeElement would, with the unknown elements at their position among its
+// child elements.
+func encodeElement(e *xml.Encoder, start xml.StartElement, v interface{}, unknown []Extension) error {
+	start = elementStart(start, v)
+	if len(unknown) == 0 {
+		return e.EncodeElement(v, start)
+	}
+
+	start, err := encodeAttrs(start, v)
+	if err != nil {
+		return err
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	u := unknownEncoder{unknown: unknown}
+	if err := u.encodeChildren(e, v); err != nil {
+		return err
+	}
+	if err := u.flush(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// elementStart returns start named as the XMLName field of v, if tagged,
+// which Marshal prefers to the name given by the parent.
+func elementStart(start xml.StartElement, v interface{}) xml.StartElement {
+	field, found := reflect.TypeOf(v).Elem().FieldByName("XMLName")
+	if !found {
+		return start
+	}
+	if name := strings.Split(field.Tag.Get("xml"), ",")[0]; name != "" {
+		start.Name = xml.Name{Local: name}
+	}
+	return start
+}
+
+// encodeAttrs returns start with the attributes of v, a pointer to a struct,
+// as Marshal writes them.
+func encodeAttrs(start xml.StartElement, v interface{}) (xml.StartElement, error) {
+	rv := reflect.ValueOf(v).Elem()
+	attrs := reflect.New(rv.Type())
+	for i := 0; i < rv.NumField(); i++ {
+		if strings.Contains(rv.Type().Field(i).Tag.Get("xml"), ",attr") {
+			attrs.Elem().Field(i).Set(rv.Field(i))
+		}
+	}
+
+	var buffer bytes.Buffer
+	e := xml.NewEncoder(&buffer)
+	if err := e.EncodeElement(attrs.Interface(), start); err != nil {
+		return start, err
+	}
+	if err := e.Flush(); err != nil {
+		return start, err
+	}
+	token, err := xml.NewDecoder(&buffer).RawToken()
+	if err != nil {
+		return start, err
+	}
+	return rawStart(token.(xml.StartElement)), nil
+}
+
+/*==========================================================*/
+// ParseOptions
+func (opts ParseOptions) apply(g *Gpx) {
+	g.Attrs = qualifyAttrs(g.Attrs)
+	if !opts.Preserve {
+		g.Attrs = nil
+		g.visitExtensions(discardUnknown)
+		return
+	}
+
+	g.takeSchemaAttrs()
+	g.visitExtensions(preferPrefixes(declaredPrefixes(g.Attrs)))
+}
+
+// applyItem applies opts to an item of a Decoder, with attrs the qualified
+// attributes of <gpx>.
+func (opts ParseOptions) applyItem(item interface{}, attrs []xml.Attr) {
+	visit := discardUnknown
+	if opts.Preserve {
+		visit = preferPrefixes(declaredPrefixes(attrs))
+	}
+	switch t := item.(type) {
+	case *Metadata:
+		t.visitExtensions(visit)
+	case *Wpt:
+		t.visitExtensions(visit)
+	case *Rte:
+		t.visitExtensions(visit)
+	case *Trk:
+		t.visitExtensions(visit)
+	case *TrackPoint:
+		t.Point.visitExtensions(visit)
+	}
+}
+
+/*==========================================================*/
+// Extension
+func (item *Extension) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	decoded, err := decodeExtension(d, start)
+	if err != nil {
+		return err
+	}
+	*item = decoded
+	return nil
+}
+
+// MarshalXML writes the element under its own name, whatever start.
+func (item Extension) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	content, err := item.XML()
+	if err != nil {
+		return err
+	}
+	return encodeRaw(e, content)
+}
+
+/*==========================================================*/
+// unknownEncoder
+
+// next writes the unknown elements read before the next known child element,
+// and counts it.
+func (u *unknownEncoder) next(e *xml.Encoder) error {
+	for len(u.unknown) > 0 && u.unknown[0].position > 0 && u.unknown[0].position <= u.known+1 {
+		if err := e.Encode(u.unknown[0]); err != nil {
+			return err
+		}
+		u.unknown = u.unknown[1:]
+	}
+	u.known++
+	return nil
+}
+
+// flush writes the unknown elements left.
+func (u *unknownEncoder) flush(e *xml.Encoder) error {
+	unknown := u.unknown
+	u.unknown = nil
+	if len(unknown) == 0 {
+		return nil
+	}
+	return e.Encode(unknown)
+}
+
+// encodeChildren encodes the known child elements of the struct v as Marshal
+// would, leaving out the elements named in skip, with the unknown elements
+// read before each one.
+func (u *unknownEncoder) encodeChildren(e *xml.Encoder, v interface{}, skip ...string) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("xml")
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if field.Name == "XMLName" || name == "" || name == "-" || strings.Contains(tag, ",attr") {
+			continue
+		}
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || s == name
+		}
+		value := rv.Field(i)
+		if skipped || (strings.Contains(tag, ",omitempty") && value.IsZero()) {
+			continue
+		}
+
+		switch {
+		case value.Kind() == reflect.Ptr && value.IsNil():
+			continue
+		case value.Kind() == reflect.Slice:
+			for j := 0; j < value.Len(); j++ {
+				if err := u.next(e); err != nil {
+					return err
+				}
+				if err := e.EncodeElement(value.Index(j).Interface(), startElement(name)); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := u.next(e); err != nil {
+				return err
+			}
+			if err := e.EncodeElement(value.Interface(), startElement(name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*==========================================================*/
+// Gpx
+func (g *Gpx) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Gpx
+	return decodeElement(d, start, (*plain)(g))
+}
+
+func (g Gpx) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Gpx
+	return encodeElement(e, start, (*plain)(&g), g.Unknown)
+}
+
+// takeSchemaAttrs moves the xsi declaration and schema location of Attrs to
+// their fields, left empty if the document has none.
+func (g *Gpx) takeSchemaAttrs() {
+	g.XMLNsXsi, g.XMLSchemaLoc = "", ""
+	var xsi string
+	for _, attr := range g.Attrs {
+		if strings.HasPrefix(attr.Name.Local, "xmlns:") && attr.Value == XSI_NAMESPACE {
+			xsi = strings.TrimPrefix(attr.Name.Local, "xmlns:")
+		}
+	}
+	if xsi == "" {
+		return
+	}
+
+	attrs := g.Attrs[:0]
+	for _, attr := range g.Attrs {
+		switch attr.Name.Local {
+		case "xmlns:" + xsi:
+			g.XMLNsXsi = attr.Value
+		case xsi + ":schemaLocation":
+			g.XMLSchemaLoc = attr.Value
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+	g.Attrs = attrs
+	// The fields are written with the xsi prefix, other attributes may still
+	// use the original one.
+	for _, attr := range attrs {
+		if xsi != "xsi" && strings.HasPrefix(attr.Name.Local, xsi+":") {
+			g.Attrs = append(g.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + xsi}, Value: XSI_NAMESPACE})
+			break
+		}
+	}
+}
+
+func (g *Gpx) visitExtensions(visit extensionsVisitor) {
+	if g.Metadata != nil {
+		g.Metadata.visitExtensions(visit)
+	}
+	for i := range g.Waypoints {
+		g.Waypoints[i].visitExtensions(visit)
+	}
+	for i := range g.Routes {
+		g.Routes[i].visitExtensions(visit)
+	}
+	for i := range g.Tracks {
+		g.Tracks[i].visitExtensions(visit)
+	}
+	visit(g.Extensions, &g.Unknown)
+}
+
+/*==========================================================*/
+// Metadata
+func (md *Metadata) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Metadata
+	return decodeElement(d, start, (*plain)(md))
+}
+
+func (md Metadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Metadata
+	return encodeElement(e, start, (*plain)(&md), md.Unknown)
+}
+
+func (md *Metadata) visitExtensions(visit extensionsVisitor) {
+	visit(md.Extensions, &md.Unknown)
+}
+
+/*==========================================================*/
+// Routes
+func (r *Rte) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Rte
+	return decodeElement(d, start, (*plain)(r))
+}
+
+func (r Rte) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Rte
+	return encodeElement(e, start, (*plain)(&r), r.Unknown)
+}
+
+func (r *Rte) visitExtensions(visit extensionsVisitor) {
+	for i := range r.Waypoints {
+		r.Waypoints[i].visitExtensions(visit)
+	}
+	visit(r.Extensions, &r.Unknown)
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Trk
+	return decodeElement(d, start, (*plain)(t))
+}
+
+func (t Trk) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Trk
+	return encodeElement(e, start, (*plain)(&t), t.Unknown)
+}
+
+func (t *Trk) visitExtensions(visit extensionsVisitor) {
+	for i := range t.Segments {
+		t.Segments[i].visitExtensions(visit)
+	}
+	visit(t.Extensions, &t.Unknown)
+}
+
+func (seg *Trkseg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Trkseg
+	return decodeElement(d, start, (*plain)(seg))
+}
+
+func (seg Trkseg) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Trkseg
+	return encodeElement(e, start, (*plain)(&seg), seg.Unknown)
+}
+
+func (seg *Trkseg) visitExtensions(visit extensionsVisitor) {
+	for i := range seg.Waypoints {
+		seg.Waypoints[i].visitExtensions(visit)
+	}
+	visit(seg.Extensions, &seg.Unknown)
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain Wpt
+	return decodeElement(d, start, (*plain)(wp))
+}
+
+func (wp Wpt) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain Wpt
+	return encodeElement(e, start, (*plain)(&wp), wp.Unknown)
+}
+
+func (wp *Wpt) visitExtensions(visit extensionsVisitor) {
+	visit(wp.Extensions, &wp.Unknown)
+}
+
+/*==========================================================*/
+// gpx10
+func (g10 *gpx10) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain gpx10
+	return decodeElement(d, start, (*plain)(g10))
+}
+
+func (g10 gpx10) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain gpx10
+	return encodeElement(e, start, (*plain)(&g10), g10.Unknown)
+}
+
+func (w10 *wpt10) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain wpt10
+	return decodeElement(d, start, (*plain)(w10))
+}
+
+func (w10 wpt10) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain wpt10
+	return encodeElement(e, start, (*plain)(&w10), w10.Unknown)
+}
+
+func (r10 *rte10) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain rte10
+	return decodeElement(d, start, (*plain)(r10))
+}
+
+func (r10 rte10) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain rte10
+	return encodeElement(e, start, (*plain)(&r10), r10.Unknown)
+}
+
+func (t10 *trk10) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain trk10
+	return decodeElement(d, start, (*plain)(t10))
+}
+
+func (t10 trk10) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain trk10
+	return encodeElement(e, start, (*plain)(&t10), t10.Unknown)
+}
+
+func (s10 *trkseg10) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain trkseg10
+	return decodeElement(d, start, (*plain)(s10))
+}
+
+func (s10 trkseg10) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain trkseg10
+	return encodeElement(e, start, (*plain)(&s10), s10.Unknown)
+}
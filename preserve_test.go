@@ -0,0 +1,161 @@
+package gpxgo
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+var preserveDocument = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1" xmlns:acme="urn:acme" xmlns:xs="http://www.w3.org/2001/XMLSchema-instance" xs:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v1 http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd" acme:source="logger" version="1.1" creator="test">
+  <wpt lat="1" lon="2">
+    <name>A</name>
+    <extensions><acme:flag acme:color="red">on</acme:flag></extensions>
+  </wpt>
+  <trk>
+    <trkseg>
+      <trkpt lat="1" lon="2">
+        <speed>3.5</speed>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+  <acme:summary>done</acme:summary>
+</gpx>`)
+
+func TestPreserveRoundTrip(t *testing.T) {
+	gpx, err := ParseWithOptions(bytes.NewReader(preserveDocument), ParseOptions{Preserve: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v1 http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd", gpx.XMLSchemaLoc)
+	assert.Equal(t, 1, len(gpx.Tracks[0].Segments[0].Waypoints[0].Unknown))
+
+	content := string(gpx.ToXML())
+	for _, expected := range []string{
+		`xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd http://www.garmin.com/xmlschemas/TrackPointExtension/v1 http://www.garmin.com/xmlschemas/TrackPointExtensionv1.xsd"`,
+		`xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1"`,
+		`xmlns:acme="urn:acme" acme:source="logger">`,
+		`<acme:flag xmlns:acme="urn:acme" acme:color="red">on</acme:flag>`,
+		`<speed>3.5</speed>`,
+		`<acme:summary xmlns:acme="urn:acme">done</acme:summary>`,
+	} {
+		assert.Equal(t, true, strings.Contains(content, expected), expected)
+	}
+
+	// Writing again what was read gives the same document.
+	reparsed, err := ParseWithOptions(strings.NewReader(content), ParseOptions{Preserve: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, content, string(reparsed.ToXML()))
+
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, reparsed.WriteXML(&buffer))
+	assert.Equal(t, content, buffer.String())
+}
+
+func TestParseDropsUnknown(t *testing.T) {
+	gpx, err := ParseWithContent(preserveDocument)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(gpx.Attrs))
+	assert.Equal(t, 0, len(gpx.Unknown))
+	assert.Equal(t, 0, len(gpx.Tracks[0].Segments[0].Waypoints[0].Unknown))
+	assert.Equal(t, GPX11_SCHEMA_LOCATION, gpx.XMLSchemaLoc)
+
+	// Extensions are still written with their namespaces.
+	content := string(gpx.ToXML())
+	assert.Equal(t, false, strings.Contains(content, "summary"))
+	assert.Equal(t, true, strings.Contains(content, `<gpxtpx:TrackPointExtension xmlns:gpxtpx="`+TPX_V1_NAMESPACE+`">`))
+	assert.Equal(t, true, strings.Contains(content, `<ns1:flag xmlns:ns1="urn:acme" ns1:color="red">on</ns1:flag>`))
+}
+
+func TestDecoderPreserve(t *testing.T) {
+	for _, preserve := range []bool{true, false} {
+		dec := NewDecoder(bytes.NewReader(preserveDocument))
+		dec.Options.Preserve = preserve
+		var items []interface{}
+		for {
+			item, err := dec.Next()
+			if err != nil {
+				break
+			}
+			items = append(items, item)
+		}
+
+		point := items[2].(*TrackPoint)
+		flag, _ := items[0].(*Wpt).Extensions.XML()
+		if preserve {
+			assert.Equal(t, 1, len(point.Point.Unknown))
+			assert.Equal(t, `<acme:flag xmlns:acme="urn:acme" acme:color="red">on</acme:flag>`, flag)
+		} else {
+			assert.Equal(t, 0, len(point.Point.Unknown))
+			assert.Equal(t, `<ns1:flag xmlns:ns1="urn:acme" ns1:color="red">on</ns1:flag>`, flag)
+		}
+	}
+}
+
+func TestPreserveGpx10PrivateElements(t *testing.T) {
+	document := `<?xml version="1.0"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/0" xmlns:acme="urn:acme" version="1.0" creator="test">
+  <wpt lat="1" lon="2"><name>A</name><acme:flag>on</acme:flag></wpt>
+</gpx>`
+	gpx, err := ParseWithOptions(strings.NewReader(document), ParseOptions{Preserve: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(gpx.Waypoints[0].Unknown))
+
+	content := string(gpx.ToXML())
+	assert.Equal(t, true, strings.Contains(content, `xmlns:acme="urn:acme"`))
+	assert.Equal(t, true, strings.Contains(content, `<acme:flag xmlns:acme="urn:acme">on</acme:flag>`))
+}
+
+func TestPreservePositions(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:x="urn:x" xmlns:tpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1" version="1.1" creator="test">
+  <x:first>1</x:first>
+  <wpt lat="1" lon="2">
+    <x:foo>2</x:foo>
+    <name>A</name>
+  </wpt>
+  <x:between>3</x:between>
+  <trk>
+    <name>T</name>
+    <x:head>4</x:head>
+    <trkseg>
+      <trkpt lat="1" lon="2"></trkpt>
+      <x:gap>5</x:gap>
+      <trkpt lat="1" lon="3">
+        <extensions><tpx:TrackPointExtension><tpx:hr>120</tpx:hr></tpx:TrackPointExtension></extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+	gpx, err := ParseWithOptions(strings.NewReader(document), ParseOptions{Preserve: true})
+	assert.Equal(t, nil, err)
+
+	content := string(gpx.ToXML())
+	assert.Equal(t, false, strings.Contains(content, "xsi"))
+	assert.Equal(t, true, strings.Contains(content, `<tpx:TrackPointExtension xmlns:tpx="`+TPX_V1_NAMESPACE+`"><tpx:hr>120</tpx:hr></tpx:TrackPointExtension>`))
+	// Unknown elements are written where they were read.
+	order := []string{"<x:first", "<wpt", "<x:foo", "<name>A", "<x:between", "<trk>", "<name>T", "<x:head", "<trkseg>", `<trkpt lat="1" lon="2">`, "<x:gap", `<trkpt lat="1" lon="3">`}
+	for i := 1; i < len(order); i++ {
+		assert.Equal(t, true, strings.Index(content, order[i-1]) < strings.Index(content, order[i]), order[i])
+	}
+
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteXML(&buffer))
+	assert.Equal(t, content, buffer.String())
+}
+
+func TestPreserveIndentedUnknown(t *testing.T) {
+	document := "<gpx xmlns=\"http://www.topografix.com/GPX/1/1\" xmlns:x=\"urn:x\" version=\"1.1\" creator=\"test\">" +
+		"<wpt lat=\"1\" lon=\"2\"><x:a>\n\t\t<x:b>1 &amp; 2</x:b>\n\t</x:a></wpt></gpx>"
+	gpx, err := ParseWithOptions(strings.NewReader(document), ParseOptions{Preserve: true})
+	assert.Equal(t, nil, err)
+
+	// The content of unknown elements is written as read.
+	expected := "<x:a xmlns:x=\"urn:x\">\n\t\t<x:b>1 &amp; 2</x:b>\n\t</x:a>"
+	content := string(gpx.ToXML())
+	assert.Equal(t, true, strings.Contains(content, expected), content)
+
+	var buffer bytes.Buffer
+	assert.Equal(t, nil, gpx.WriteXML(&buffer))
+	assert.Equal(t, content, buffer.String())
+}
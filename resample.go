@@ -0,0 +1,95 @@
+package gpxgo
+
+import (
+	"math"
+	"time"
+)
+
+// interpolator is a registered extension type whose numeric values can be
+// interpolated between points.
+type interpolator interface {
+	// interpolate sets the numeric values to those between v and v2, of the
+	// same type, at fraction. Values missing in either are left unchanged.
+	interpolate(v, v2 interface{}, fraction float64)
+}
+
+/*==========================================================*/
+// Static
+
+// interpolateExtensions returns a copy of the extensions of the nearer point,
+// with the numeric values of the registered types that support it, such as
+// heart rate, cadence and temperature, interpolated at fraction from ext to
+// ext2.
+func interpolateExtensions(ext, ext2 *Extensions, fraction float64) *Extensions {
+	nearest := ext
+	if fraction > 0.5 {
+		nearest = ext2
+	}
+	interpolated := nearest.copy()
+	if ext == nil || ext2 == nil {
+		return interpolated
+	}
+
+	for _, item := range interpolated.Items {
+		value, ok := item.Value.(interpolator)
+		if !ok {
+			continue
+		}
+		v := ext.Get(item.Name.Space, item.Name.Local)
+		v2 := ext2.Get(item.Name.Space, item.Name.Local)
+		if v != nil && v2 != nil {
+			value.interpolate(v, v2, fraction)
+		}
+	}
+	return interpolated
+}
+
+func interpolateFloat(value *NullableFloat64, v, v2 NullableFloat64, fraction float64) {
+	if v.Valid && v2.Valid {
+		value.SetValue(v.Float64 + (v2.Float64-v.Float64)*fraction)
+	}
+}
+
+func interpolateInt(value *NullableInt, v, v2 NullableInt, fraction float64) {
+	if v.Valid && v2.Valid {
+		value.SetValue(int(math.Round(float64(v.Int) + float64(v2.Int-v.Int)*fraction)))
+	}
+}
+
+// resampleWaypoints returns points every step along wps, as measured by
+// measure on each leg. The first and last points are kept.
+func resampleWaypoints(wps Waypoints, step float64, measure func(wp, wp2 *Wpt) float64) Waypoints {
+	if len(wps) < 2 || step <= 0 {
+		return wps
+	}
+
+	resampled := Waypoints{wps[0]}
+	walker := stepWalker{step: step, measure: measure}
+	// lastCut is whether the last point visited, the last of wps, was a cut.
+	var lastCut bool
+	walker.walk(wps, func(wp Wpt, cut bool) {
+		if cut {
+			resampled = append(resampled, wp)
+		}
+		lastCut = cut
+	})
+	if !lastCut {
+		resampled = append(resampled, wps[len(wps)-1])
+	}
+	return resampled
+}
+
+/*==========================================================*/
+// Trkseg
+
+// ResampleByDistance replaces the points with points every meters along the
+// segment. New points are interpolated with Wpt.Interpolate.
+func (ts *Trkseg) ResampleByDistance(meters float64) {
+	ts.Waypoints = resampleWaypoints(ts.Waypoints, meters, distanceMeasure)
+}
+
+// ResampleByDuration replaces the points with points every d. Points are only
+// placed on legs where both ends have a time.
+func (ts *Trkseg) ResampleByDuration(d time.Duration) {
+	ts.Waypoints = resampleWaypoints(ts.Waypoints, d.Seconds(), durationMeasure)
+}
@@ -0,0 +1,106 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestResampleByDistance(t *testing.T) {
+	seg := newSplitTestTrack().Segments[0]
+	seg.Waypoints = seg.Waypoints[:4]
+	length := seg.Length2D()
+
+	seg.ResampleByDistance(50)
+	assert.Equal(t, int(length/50)+2, len(seg.Waypoints))
+	for i := 1; i < len(seg.Waypoints)-1; i++ {
+		assert.Equal(t, true, math.Abs(seg.Waypoints[i].Length2D(&seg.Waypoints[i-1])-50) < 0.01)
+		assert.Equal(t, true, seg.Waypoints[i].Time.Valid)
+		assert.Equal(t, true, seg.Waypoints[i].Ele.Valid)
+	}
+	assert.Equal(t, true, math.Abs(seg.Length2D()-length) < 0.01)
+}
+
+func TestResampleByDuration(t *testing.T) {
+	seg := newSplitTestTrack().Segments[0]
+	seg.Waypoints = seg.Waypoints[:3]
+
+	seg.ResampleByDuration(10 * time.Second)
+	assert.Equal(t, 7, len(seg.Waypoints))
+	start := seg.Waypoints[0].Time.Time
+	for i, wp := range seg.Waypoints {
+		assert.Equal(t, start.Add(time.Duration(i)*10*time.Second), wp.Time.Time)
+	}
+	// Elevation goes 0 -> 10 -> 0 over 60 s.
+	assert.Equal(t, true, math.Abs(seg.Waypoints[1].Ele.Float64-10./3) < 0.000001)
+	assert.Equal(t, true, math.Abs(seg.Waypoints[0].Lat+0.001/3-seg.Waypoints[1].Lat) < 0.0000001)
+}
+
+func TestResampleOnPoints(t *testing.T) {
+	seg := newSplitTestTrack().Segments[0]
+	seg.Waypoints = seg.Waypoints[:5]
+	wps := append(Waypoints(nil), seg.Waypoints...)
+
+	// Samples landing on points keep them, once, with no sample after the last.
+	seg.ResampleByDistance(2 * distanceMeasure(&wps[0], &wps[1]))
+	assert.Equal(t, Waypoints{wps[0], wps[2], wps[4]}, seg.Waypoints)
+
+	seg.Waypoints = append(Waypoints(nil), wps...)
+	seg.ResampleByDuration(time.Minute)
+	assert.Equal(t, Waypoints{wps[0], wps[2], wps[4]}, seg.Waypoints)
+}
+
+func TestResampleUntimed(t *testing.T) {
+	seg := newSplitTestTrack().Segments[0]
+	wps := append(Waypoints(nil), seg.Waypoints[:3]...)
+	wps[2].Time.SetNull()
+
+	// The untimed tail keeps its last point.
+	seg.Waypoints = append(Waypoints(nil), wps...)
+	seg.ResampleByDuration(10 * time.Second)
+	assert.Equal(t, 5, len(seg.Waypoints))
+	assert.Equal(t, wps[1], seg.Waypoints[3])
+	assert.Equal(t, wps[2], seg.Waypoints[4])
+
+	// So does a segment without any time.
+	for i := range wps {
+		wps[i].Time.SetNull()
+	}
+	seg.Waypoints = append(Waypoints(nil), wps...)
+	seg.ResampleByDuration(10 * time.Second)
+	assert.Equal(t, Waypoints{wps[0], wps[2]}, seg.Waypoints)
+}
+
+func TestInterpolateExtensions(t *testing.T) {
+	wp := Wpt{Lat: 0, Lon: 0, Extensions: new(Extensions)}
+	wp.Extensions.AddXML(`<gpxtpx:TrackPointExtension xmlns:gpxtpx="` + TPX_V1_NAMESPACE + `"><gpxtpx:atemp>20.5</gpxtpx:atemp><gpxtpx:hr>120</gpxtpx:hr></gpxtpx:TrackPointExtension><power xmlns="urn:vendor">200.0</power>`)
+	wp2 := Wpt{Lat: 0, Lon: 0.001, Extensions: new(Extensions)}
+	wp2.Extensions.AddXML(`<gpxtpx:TrackPointExtension xmlns:gpxtpx="` + TPX_V1_NAMESPACE + `"><gpxtpx:atemp>21.5</gpxtpx:atemp><gpxtpx:hr>131</gpxtpx:hr></gpxtpx:TrackPointExtension><power xmlns="urn:vendor">250.0</power>`)
+
+	interpolated := wp.Interpolate(&wp2, 0.5)
+	tpx, _ := interpolated.TrackPointExtension()
+	assert.Equal(t, NewNullableFloat64(21), tpx.ATemp)
+	assert.Equal(t, NewNullableInt(126), tpx.HR)
+	// Unregistered elements, which may be IDs or enums, are not interpolated.
+	power, _ := interpolated.Extensions.Items[1].XML()
+	assert.Equal(t, `<power xmlns="urn:vendor">200.0</power>`, power)
+
+	// The extensions are copies.
+	tpx.HR.SetValue(60)
+	tpx, _ = interpolated.TrackPointExtension()
+	assert.Equal(t, NewNullableInt(60), tpx.HR)
+	tpx, _ = wp.TrackPointExtension()
+	assert.Equal(t, NewNullableInt(120), tpx.HR)
+	nearest := wp.Interpolate(&wp2, 0.2)
+	tpx, _ = nearest.TrackPointExtension()
+	tpx.HR.SetValue(60)
+	tpx, _ = wp.TrackPointExtension()
+	assert.Equal(t, NewNullableInt(120), tpx.HR)
+
+	// Different structures are copied from the nearer point.
+	wp2.Extensions = new(Extensions)
+	wp2.Extensions.AddXML(`<other>1</other>`)
+	assert.Equal(t, wp.Extensions, wp.Interpolate(&wp2, 0.2).Extensions)
+	assert.Equal(t, wp2.Extensions, wp.Interpolate(&wp2, 0.8).Extensions)
+}
@@ -0,0 +1,151 @@
+package gpxgo
+
+import (
+	"math"
+)
+
+/*==========================================================*/
+// Static
+
+// distanceFromSegment returns the distance of p from the segment a-b. The
+// three sides of the triangle are measured with Distance, so the result is
+// consistent with Length2D/Length3D.
+func distanceFromSegment(p, a, b *Wpt, threeD bool) float64 {
+	length := a.Length2D
+	if threeD {
+		length = a.Length3D
+	}
+	ab := length(b)
+	ap := length(p)
+	bp := p.Length2D(b)
+	if threeD {
+		bp = p.Length3D(b)
+	}
+
+	if ab == 0 {
+		return ap
+	}
+	// The projection of p falls outside the segment.
+	if ap*ap > ab*ab+bp*bp {
+		return bp
+	}
+	if bp*bp > ab*ab+ap*ap {
+		return ap
+	}
+
+	// Heron's formula
+	area2 := (ab + ap + bp) * (-ab + ap + bp) * (ab - ap + bp) * (ab + ap - bp)
+	if area2 <= 0 {
+		return 0.0
+	}
+	return math.Sqrt(area2) / 2 / ab
+}
+
+// simplifyWaypoints applies Ramer-Douglas-Peucker to wps.
+func simplifyWaypoints(wps Waypoints, maxDistance float64, threeD bool) Waypoints {
+	if len(wps) < 3 {
+		return wps
+	}
+
+	keep := make([]bool, len(wps))
+	keep[0] = true
+	keep[len(wps)-1] = true
+
+	stack := [][2]int{{0, len(wps) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		var (
+			maxFound float64
+			index    int
+		)
+		for i := first + 1; i < last; i++ {
+			d := distanceFromSegment(&wps[i], &wps[first], &wps[last], threeD)
+			if d > maxFound {
+				maxFound = d
+				index = i
+			}
+		}
+		if maxFound > maxDistance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	simplified := make(Waypoints, 0, len(wps))
+	for i, wp := range wps {
+		if keep[i] {
+			simplified = append(simplified, wp)
+		}
+	}
+	return simplified
+}
+
+/*==========================================================*/
+// Gpx
+
+// Simplify drops track and route points while keeping every removed point
+// within maxDistance meters of the simplified line.
+func (g *Gpx) Simplify(maxDistance float64) {
+	g.simplify(maxDistance, false)
+}
+
+// Simplify3D is Simplify with the tolerance measured in 3D, so elevation
+// changes are kept too.
+func (g *Gpx) Simplify3D(maxDistance float64) {
+	g.simplify(maxDistance, true)
+}
+
+func (g *Gpx) simplify(maxDistance float64, threeD bool) {
+	for i := range g.Routes {
+		g.Routes[i].simplify(maxDistance, threeD)
+	}
+	for i := range g.Tracks {
+		g.Tracks[i].simplify(maxDistance, threeD)
+	}
+}
+
+/*==========================================================*/
+// Routes
+func (r *Rte) Simplify(maxDistance float64) {
+	r.simplify(maxDistance, false)
+}
+
+func (r *Rte) Simplify3D(maxDistance float64) {
+	r.simplify(maxDistance, true)
+}
+
+func (r *Rte) simplify(maxDistance float64, threeD bool) {
+	r.Waypoints = simplifyWaypoints(r.Waypoints, maxDistance, threeD)
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) Simplify(maxDistance float64) {
+	t.simplify(maxDistance, false)
+}
+
+func (t *Trk) Simplify3D(maxDistance float64) {
+	t.simplify(maxDistance, true)
+}
+
+func (t *Trk) simplify(maxDistance float64, threeD bool) {
+	for i := range t.Segments {
+		t.Segments[i].simplify(maxDistance, threeD)
+	}
+}
+
+/*==========================================================*/
+// Trkseg
+func (ts *Trkseg) Simplify(maxDistance float64) {
+	ts.simplify(maxDistance, false)
+}
+
+func (ts *Trkseg) Simplify3D(maxDistance float64) {
+	ts.simplify(maxDistance, true)
+}
+
+func (ts *Trkseg) simplify(maxDistance float64, threeD bool) {
+	ts.Waypoints = simplifyWaypoints(ts.Waypoints, maxDistance, threeD)
+}
@@ -0,0 +1,57 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	seg := Trkseg{}
+	// A straight line along the equator with a 5 m wobble and a 200 m detour.
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0, Lon: 0})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0.00005, Lon: 0.001})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0, Lon: 0.002})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0.002, Lon: 0.003})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0, Lon: 0.004})
+	seg.Waypoints = append(seg.Waypoints, Wpt{Lat: 0, Lon: 0.005})
+
+	simplified := seg
+	simplified.Simplify(10)
+	assert.Equal(t, 5, len(simplified.Waypoints))
+	assert.Equal(t, seg.Waypoints[0], simplified.Waypoints[0])
+	assert.Equal(t, seg.Waypoints[2], simplified.Waypoints[1])
+	assert.Equal(t, seg.Waypoints[3], simplified.Waypoints[2])
+	assert.Equal(t, seg.Waypoints[5], simplified.Waypoints[4])
+
+	simplified = seg
+	simplified.Simplify(1)
+	assert.Equal(t, 6, len(simplified.Waypoints))
+
+	simplified = seg
+	simplified.Simplify(1000)
+	assert.Equal(t, 2, len(simplified.Waypoints))
+}
+
+func TestSimplify3D(t *testing.T) {
+	rte := Rte{}
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 0, Lon: 0, Ele: NewNullableFloat64(0)})
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 0, Lon: 0.001, Ele: NewNullableFloat64(50)})
+	rte.Waypoints = append(rte.Waypoints, Wpt{Lat: 0, Lon: 0.002, Ele: NewNullableFloat64(0)})
+
+	flat := rte
+	flat.Simplify(10)
+	assert.Equal(t, 2, len(flat.Waypoints))
+
+	rte.Simplify3D(10)
+	assert.Equal(t, 3, len(rte.Waypoints))
+}
+
+func TestSimplifyGpx(t *testing.T) {
+	gpx, err := ParseWithPath("testdata/St_Louis_Zoo_sample.gpx")
+	assert.Equal(t, nil, err)
+
+	count := len(gpx.Tracks[0].Segments[0].Waypoints)
+	gpx.Simplify(5)
+	assert.Equal(t, true, len(gpx.Tracks[0].Segments[0].Waypoints) < count)
+	assert.Equal(t, 10, len(gpx.Waypoints))
+}
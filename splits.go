@@ -0,0 +1,177 @@
+package gpxgo
+
+import (
+	"time"
+)
+
+// Split is one lap of an activity cut every N meters or N seconds. Segment
+// holds the points of the lap, starting and ending on interpolated points at
+// the cut; a lap over several track segments holds the points of all, but
+// the gaps between them count in none of its values. Pace is based on the
+// moving time.
+type Split struct {
+	Index      int
+	Segment    Trkseg
+	Distance   float64
+	Elapsed    time.Duration
+	MovingTime time.Duration
+	Pace       time.Duration
+	Ascent     float64
+	Descent    float64
+}
+
+/*==========================================================*/
+// Static
+func distanceMeasure(wp, wp2 *Wpt) float64 {
+	return wp2.Length2D(wp)
+}
+
+func durationMeasure(wp, wp2 *Wpt) float64 {
+	if !wp.Time.Valid || !wp2.Time.Valid {
+		return 0.0
+	}
+	return wp2.Time.Time.Sub(wp.Time.Time).Seconds()
+}
+
+// newSplit returns the split of the parts of the lap in each track segment.
+func newSplit(index int, parts []Trkseg) Split {
+	split := Split{Index: index}
+	for i := range parts {
+		part := &parts[i]
+		split.Segment.Waypoints = append(split.Segment.Waypoints, part.Waypoints...)
+		split.Distance += part.Length2D()
+		split.Elapsed += part.Duration()
+		split.MovingTime += part.MovingData().MovingTime
+		ascent, descent := part.UphillDownhill()
+		split.Ascent += ascent
+		split.Descent += descent
+	}
+	if split.MovingTime > 0 {
+		split.Pace = Pace(split.Distance / split.MovingTime.Seconds())
+	}
+	return split
+}
+
+// stepWalker walks points, cutting them every step as measured by measure on
+// each leg, the measure going on from one walk to the next.
+type stepWalker struct {
+	step    float64
+	measure func(wp, wp2 *Wpt) float64
+	// acc is the measure since the last cut.
+	acc float64
+}
+
+// walk calls visit with each point of wps and with the cuts between them, in
+// order. A cut is placed at the fraction of its leg given by measure, so that
+// cuts fall every step of measure whatever the distance Interpolate moves by.
+// A cut within a rounding error of a point is that point, which is then
+// visited only once, as a cut.
+func (w *stepWalker) walk(wps Waypoints, visit func(wp Wpt, cut bool)) {
+	if len(wps) == 0 {
+		return
+	}
+	epsilon := w.step * 1e-9
+
+	visit(wps[0], false)
+	for i := 1; i < len(wps); i++ {
+		previous, point := &wps[i-1], &wps[i]
+		leg := w.measure(previous, point)
+		if leg <= 0 {
+			visit(*point, false)
+			continue
+		}
+		// offset is the measure along the leg to the next cut.
+		offset := w.step - w.acc
+		for ; offset < leg-epsilon; offset += w.step {
+			visit(*previous.Interpolate(point, offset/leg), true)
+		}
+		if offset <= leg+epsilon {
+			w.acc = 0
+			visit(*point, true)
+		} else {
+			w.acc = w.step - (offset - leg)
+			visit(*point, false)
+		}
+	}
+}
+
+// splitSegments cuts segments every step, as measured by measure on the legs
+// within each segment, the measure going on from one segment to the next.
+func splitSegments(segments []Waypoints, step float64, measure func(wp, wp2 *Wpt) float64) []Split {
+	var (
+		splits  []Split
+		parts   []Trkseg
+		current Trkseg
+	)
+	if step <= 0 {
+		return nil
+	}
+
+	walker := stepWalker{step: step, measure: measure}
+	visit := func(wp Wpt, cut bool) {
+		current.Waypoints = append(current.Waypoints, wp)
+		if cut {
+			splits = append(splits, newSplit(len(splits), append(parts, current)))
+			parts = nil
+			current = Trkseg{Waypoints: Waypoints{wp}}
+		}
+	}
+	for _, wps := range segments {
+		current = Trkseg{}
+		walker.walk(wps, visit)
+		if len(current.Waypoints) > 1 {
+			parts = append(parts, current)
+		}
+	}
+	if len(parts) > 0 && (walker.acc > 0 || len(splits) == 0) {
+		splits = append(splits, newSplit(len(splits), parts))
+	}
+	return splits
+}
+
+/*==========================================================*/
+// Gpx
+
+// SplitsByDistance cuts all tracks, one after the other, every meters of
+// their segments.
+func (g *Gpx) SplitsByDistance(meters float64) []Split {
+	return splitSegments(g.segmentWaypoints(), meters, distanceMeasure)
+}
+
+// SplitsByDuration cuts all tracks, one after the other, every d of
+// elapsed time within their segments. Legs without time on both ends are
+// never cut.
+func (g *Gpx) SplitsByDuration(d time.Duration) []Split {
+	return splitSegments(g.segmentWaypoints(), d.Seconds(), durationMeasure)
+}
+
+func (g *Gpx) segmentWaypoints() []Waypoints {
+	var segments []Waypoints
+	for i := range g.Tracks {
+		segments = append(segments, g.Tracks[i].segmentWaypoints()...)
+	}
+	return segments
+}
+
+/*==========================================================*/
+// Tracks
+
+// SplitsByDistance cuts the track every meters of its segments, the
+// distance going on from one segment to the next.
+func (t *Trk) SplitsByDistance(meters float64) []Split {
+	return splitSegments(t.segmentWaypoints(), meters, distanceMeasure)
+}
+
+// SplitsByDuration cuts the track every d of elapsed time within its
+// segments.
+func (t *Trk) SplitsByDuration(d time.Duration) []Split {
+	return splitSegments(t.segmentWaypoints(), d.Seconds(), durationMeasure)
+}
+
+func (t *Trk) segmentWaypoints() []Waypoints {
+	var segments []Waypoints
+	for i := range t.Segments {
+		segments = append(segments, t.Segments[i].Waypoints)
+	}
+	return segments
+}
@@ -0,0 +1,120 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func newSplitTestTrack() Trk {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	// 0.001 degree of latitude is ~111 m, covered in 30 s.
+	lats := []float64{}
+	seconds := []int{}
+	for i := 0; i <= 30; i++ {
+		lats = append(lats, float64(i)*0.001)
+		seconds = append(seconds, i*30)
+	}
+	seg := newTimedSegment(start, lats, seconds)
+	for i := range seg.Waypoints {
+		seg.Waypoints[i].Ele.SetValue(float64(i % 2 * 10))
+	}
+	return Trk{Segments: []Trkseg{seg}}
+}
+
+func TestSplitsByDistance(t *testing.T) {
+	trk := newSplitTestTrack()
+	total := trk.Length2D()
+
+	splits := trk.SplitsByDistance(1000)
+	assert.Equal(t, int(total/1000)+1, len(splits))
+
+	var distance float64
+	for i, split := range splits {
+		assert.Equal(t, i, split.Index)
+		if i < len(splits)-1 {
+			assert.Equal(t, true, math.Abs(split.Distance-1000) < 0.5)
+			assert.Equal(t, true, math.Abs(split.Elapsed.Seconds()-1000/111.2*30) < 1)
+			assert.Equal(t, split.Elapsed, split.MovingTime)
+			assert.Equal(t, true, split.Pace > 4*time.Minute && split.Pace < 5*time.Minute)
+			assert.Equal(t, true, split.Ascent > 0)
+		}
+		if i > 0 {
+			previous := splits[i-1].Segment.Waypoints
+			assert.Equal(t, previous[len(previous)-1], split.Segment.Waypoints[0])
+		}
+		distance += split.Distance
+	}
+	assert.Equal(t, true, math.Abs(distance-total) < 1)
+}
+
+func TestSplitsByDuration(t *testing.T) {
+	trk := newSplitTestTrack()
+
+	splits := trk.SplitsByDuration(5 * time.Minute)
+	assert.Equal(t, 3, len(splits))
+	for _, split := range splits {
+		assert.Equal(t, 5*time.Minute, split.Elapsed)
+	}
+
+	gpx := NewGpx()
+	gpx.Tracks = append(gpx.Tracks, trk)
+	assert.Equal(t, splits, gpx.SplitsByDuration(5*time.Minute))
+	assert.Equal(t, 0, len(NewGpx().SplitsByDistance(1000)))
+}
+
+func TestSplitsAcrossSegments(t *testing.T) {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	seconds := []int{0, 30, 60, 90, 120, 150}
+	// An hour later and ~11 km away
+	trk := Trk{Segments: []Trkseg{
+		newTimedSegment(start, []float64{0, 0.001, 0.002, 0.003, 0.004, 0.005}, seconds),
+		newTimedSegment(start.Add(time.Hour), []float64{0.1, 0.101, 0.102, 0.103, 0.104, 0.105}, seconds),
+	}}
+
+	splits := trk.SplitsByDistance(1000)
+	assert.Equal(t, 2, len(splits))
+	assert.Equal(t, true, math.Abs(splits[0].Distance-1000) < 0.5)
+	assert.Equal(t, true, math.Abs(splits[0].Elapsed.Seconds()-1000/111.2*30) < 1)
+	assert.Equal(t, true, math.Abs(splits[0].Distance+splits[1].Distance-trk.Length2D()) < 0.01)
+	assert.Equal(t, 11, len(splits[0].Segment.Waypoints))
+
+	splits = trk.SplitsByDuration(2 * time.Minute)
+	assert.Equal(t, 3, len(splits))
+	assert.Equal(t, 2*time.Minute, splits[1].Elapsed)
+	assert.Equal(t, time.Minute, splits[2].Elapsed)
+
+	// Tracks go on one after the other.
+	gpx := NewGpx()
+	gpx.Tracks = []Trk{{Segments: trk.Segments[:1]}, {Segments: trk.Segments[1:]}}
+	assert.Equal(t, splits, gpx.SplitsByDuration(2*time.Minute))
+}
+
+func TestSplitsOnPoints(t *testing.T) {
+	start := time.Date(2016, 1, 22, 21, 56, 41, 0, time.UTC)
+	lats := []float64{}
+	seconds := []int{}
+	for i := 0; i <= 10; i++ {
+		lats = append(lats, float64(i)*0.001)
+		seconds = append(seconds, i*30)
+	}
+	trk := Trk{Segments: []Trkseg{newTimedSegment(start, lats, seconds)}}
+	wps := trk.Segments[0].Waypoints
+
+	// Cuts landing on points keep them, once, and leave no trailing split.
+	leg := distanceMeasure(&wps[0], &wps[1])
+	for _, splits := range [][]Split{trk.SplitsByDistance(2 * leg), trk.SplitsByDuration(time.Minute)} {
+		assert.Equal(t, 5, len(splits))
+		for i, split := range splits {
+			assert.Equal(t, wps[2*i:2*i+3], split.Segment.Waypoints)
+		}
+	}
+
+	// Cuts between points fall on the step.
+	splits := trk.SplitsByDistance(250)
+	assert.Equal(t, 5, len(splits))
+	for _, split := range splits[:4] {
+		assert.Equal(t, true, math.Abs(split.Distance-250) < 1e-3)
+	}
+}
@@ -0,0 +1,420 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"golang.org/x/net/html/charset"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TCX sports of activities
+const (
+	TCX_RUNNING = "Running"
+	TCX_BIKING  = "Biking"
+	TCX_OTHER   = "Other"
+)
+
+// Characters of the longest course and course point names, as the TCX schema
+// restricts them
+const (
+	tcxMaxCourseName      = 15
+	tcxMaxCoursePointName = 10
+)
+
+// The PointType of TCX course points, other types are written as Generic.
+var tcxPointTypes = []string{
+	"Generic", "Summit", "Valley", "Water", "Food", "Danger", "Left", "Right", "Straight", "First Aid",
+	"4th Category", "3rd Category", "2nd Category", "1st Category", "Hors Category", "Sprint",
+}
+
+type tcxDatabase struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	XMLNs      string        `xml:"xmlns,attr"`
+	Activities []tcxActivity `xml:"Activities>Activity,omitempty"`
+	Courses    []tcxCourse   `xml:"Courses>Course,omitempty"`
+}
+
+type tcxActivity struct {
+	Sport string   `xml:"Sport,attr"`
+	ID    string   `xml:"Id"`
+	Laps  []tcxLap `xml:"Lap"`
+	Notes string   `xml:"Notes,omitempty"`
+}
+
+type tcxLap struct {
+	StartTime        string     `xml:"StartTime,attr"`
+	TotalTimeSeconds float64    `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64    `xml:"DistanceMeters"`
+	Calories         int        `xml:"Calories"`
+	Intensity        string     `xml:"Intensity"`
+	TriggerMethod    string     `xml:"TriggerMethod"`
+	Tracks           []tcxTrack `xml:"Track"`
+}
+
+type tcxCourse struct {
+	Name   string           `xml:"Name"`
+	Laps   []tcxCourseLap   `xml:"Lap"`
+	Tracks []tcxTrack       `xml:"Track"`
+	Notes  string           `xml:"Notes,omitempty"`
+	Points []tcxCoursePoint `xml:"CoursePoint"`
+}
+
+type tcxCourseLap struct {
+	TotalTimeSeconds float64      `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64      `xml:"DistanceMeters"`
+	BeginPosition    *tcxPosition `xml:"BeginPosition,omitempty"`
+	EndPosition      *tcxPosition `xml:"EndPosition,omitempty"`
+	Intensity        string       `xml:"Intensity"`
+}
+
+type tcxCoursePoint struct {
+	Name      string          `xml:"Name"`
+	Time      NullableTime    `xml:"Time"`
+	Position  tcxPosition     `xml:"Position"`
+	Altitude  NullableFloat64 `xml:"AltitudeMeters"`
+	PointType string          `xml:"PointType"`
+	Notes     string          `xml:"Notes,omitempty"`
+}
+
+type tcxTrack struct {
+	Points []tcxPoint `xml:"Trackpoint"`
+}
+
+type tcxPoint struct {
+	Time       NullableTime        `xml:"Time"`
+	Position   *tcxPosition        `xml:"Position,omitempty"`
+	Altitude   NullableFloat64     `xml:"AltitudeMeters"`
+	Distance   NullableFloat64     `xml:"DistanceMeters"`
+	HeartRate  *tcxHeartRate       `xml:"HeartRateBpm,omitempty"`
+	Cadence    NullableInt         `xml:"Cadence"`
+	Extensions *tcxPointExtensions `xml:"Extensions,omitempty"`
+}
+
+type tcxPosition struct {
+	Lat float64 `xml:"LatitudeDegrees"`
+	Lon float64 `xml:"LongitudeDegrees"`
+}
+
+type tcxHeartRate struct {
+	Value int `xml:"Value"`
+}
+
+type tcxPointExtensions struct {
+	TPX *ActivityExtension `xml:"http://www.garmin.com/xmlschemas/ActivityExtension/v2 TPX"`
+}
+
+/*==========================================================*/
+// Static
+
+// ParseTCXWithReader converts a Garmin Training Center XML document: each
+// activity is read as a track with a segment per lap, named after the
+// activity Id and typed with its sport, and each course as a route, its
+// course points as waypoints. Heart rate and cadence are kept in the
+// TrackPointExtension of the points, speed and watts in their
+// ActivityExtension and the distance as DistanceMeters. Track points without
+// a position are skipped. Gzip and bzip2 compressed documents are
+// decompressed.
+func ParseTCXWithReader(r io.Reader) (*Gpx, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	var db tcxDatabase
+	if err := d.Decode(&db); err != nil {
+		return nil, err
+	}
+
+	gpx := NewGpx()
+	for _, activity := range db.Activities {
+		gpx.Tracks = append(gpx.Tracks, activity.toTrk())
+	}
+	for _, course := range db.Courses {
+		gpx.Routes = append(gpx.Routes, course.toRte())
+		for _, point := range course.Points {
+			gpx.Waypoints = append(gpx.Waypoints, point.toWpt())
+		}
+	}
+	return gpx, nil
+}
+
+func ParseTCXWithContent(content []byte) (*Gpx, error) {
+	return ParseTCXWithReader(bytes.NewReader(content))
+}
+
+func tcxSport(typ string) string {
+	for _, sport := range []string{TCX_RUNNING, TCX_BIKING} {
+		if strings.EqualFold(typ, sport) {
+			return sport
+		}
+	}
+	return TCX_OTHER
+}
+
+func tcxPointType(typ string) string {
+	for _, pointType := range tcxPointTypes {
+		if strings.EqualFold(typ, pointType) {
+			return pointType
+		}
+	}
+	return tcxPointTypes[0]
+}
+
+// tcxTracksOf returns the TCX track of points, none if no point has a time,
+// with distance the distance at the first point. Points with a
+// DistanceMeters keep it, the others get the distance travelled since.
+// Points without time, which TCX requires, are left out.
+func tcxTracksOf(points Waypoints, distance float64) ([]tcxTrack, float64) {
+	var track tcxTrack
+	for i := range points {
+		if i > 0 {
+			distance += points[i].Length2D(&points[i-1])
+		}
+		if stored := points[i].DistanceMeters(); stored.Valid {
+			distance = stored.Float64
+		}
+		if points[i].Time.IsNull() {
+			continue
+		}
+		track.Points = append(track.Points, points[i].tcxPoint(points[i].Time, distance))
+	}
+	if len(track.Points) == 0 {
+		return nil, distance
+	}
+	return []tcxTrack{track}, distance
+}
+
+// nearestRoute returns the indexes of the route and of its point nearest to
+// wp, -1 if there are no route points.
+func nearestRoute(routes []Rte, wp *Wpt) (int, int) {
+	route, point, min := -1, -1, math.MaxFloat64
+	for i := range routes {
+		for j := range routes[i].Waypoints {
+			if length := wp.Length2D(&routes[i].Waypoints[j]); length < min {
+				route, point, min = i, j, length
+			}
+		}
+	}
+	return route, point
+}
+
+// tcxToken returns s cut to max characters.
+func tcxToken(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+/*==========================================================*/
+// Gpx
+
+// ToTCX converts the document to Garmin Training Center XML, the reverse of
+// ParseTCXWithReader: each track is an activity with a lap per segment, each
+// route a course, and each waypoint a course point of the course passing
+// nearest to it. Without routes, waypoints are not written. TCX requiring
+// times, track points without time are left out, and so are segments and
+// tracks without any timed point. Route points without time are given one as
+// in WriteFITCourse, and waypoints without time that of the nearest route
+// point. Lap totals and missing point distances are computed from all the
+// points. Course names are cut to 15 characters and course point names to 10.
+func (g *Gpx) ToTCX() ([]byte, error) {
+	db := tcxDatabase{XMLNs: TCX_NAMESPACE}
+	for i := range g.Tracks {
+		if activity, ok := g.Tracks[i].tcxActivity(); ok {
+			db.Activities = append(db.Activities, activity)
+		}
+	}
+	courseTimes := make([][]time.Time, len(g.Routes))
+	for i := range g.Routes {
+		var course tcxCourse
+		course, courseTimes[i] = g.Routes[i].tcxCourse()
+		db.Courses = append(db.Courses, course)
+	}
+	for i := range g.Waypoints {
+		wp := &g.Waypoints[i]
+		course, nearest := nearestRoute(g.Routes, wp)
+		if course < 0 {
+			continue
+		}
+		point := wp.tcxCoursePoint()
+		if point.Time.IsNull() {
+			point.Time = NewNullableTime(courseTimes[course][nearest])
+		}
+		db.Courses[course].Points = append(db.Courses[course].Points, point)
+	}
+
+	content, err := xml.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// WriteTCX writes the document to w as ToTCX.
+func (g *Gpx) WriteTCX(w io.Writer) error {
+	content, err := g.ToTCX()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+/*==========================================================*/
+// Routes
+
+// tcxCourse returns the route as a course, with the times written for its
+// points.
+func (r *Rte) tcxCourse() (tcxCourse, []time.Time) {
+	course := tcxCourse{Name: tcxToken(r.Name, tcxMaxCourseName), Notes: r.Desc}
+	lap := tcxCourseLap{DistanceMeters: r.Length2D(), Intensity: "Active"}
+	var times []time.Time
+	if n := len(r.Waypoints); n > 0 {
+		var distances []float64
+		times, distances = fitTimeline(r.Waypoints, fitStart(r.Waypoints), 0)
+		var track tcxTrack
+		for i := range r.Waypoints {
+			track.Points = append(track.Points, r.Waypoints[i].tcxPoint(NewNullableTime(times[i]), distances[i]))
+		}
+		course.Tracks = []tcxTrack{track}
+		lap.TotalTimeSeconds = times[n-1].Sub(times[0]).Seconds()
+		lap.BeginPosition = &tcxPosition{Lat: r.Waypoints[0].Lat, Lon: r.Waypoints[0].Lon}
+		lap.EndPosition = &tcxPosition{Lat: r.Waypoints[n-1].Lat, Lon: r.Waypoints[n-1].Lon}
+	}
+	course.Laps = []tcxCourseLap{lap}
+	return course, times
+}
+
+/*==========================================================*/
+// Tracks
+
+// tcxActivity returns the track as an activity with a lap per segment with
+// timed points, or false if it has none, an activity needing a lap.
+func (t *Trk) tcxActivity() (tcxActivity, bool) {
+	tb := t.TimeBounds()
+	if tb == nil {
+		return tcxActivity{}, false
+	}
+	activity := tcxActivity{Sport: tcxSport(t.Type), ID: FormatTime(tb.StartTime), Notes: t.Desc}
+
+	var distance float64
+	for i := range t.Segments {
+		seg := &t.Segments[i]
+		var tracks []tcxTrack
+		tracks, distance = tcxTracksOf(seg.Waypoints, distance)
+		segBounds := seg.TimeBounds()
+		if segBounds == nil {
+			continue
+		}
+		activity.Laps = append(activity.Laps, tcxLap{
+			StartTime:        FormatTime(segBounds.StartTime),
+			TotalTimeSeconds: seg.Duration().Seconds(),
+			DistanceMeters:   seg.Length2D(),
+			Intensity:        "Active",
+			TriggerMethod:    "Manual",
+			Tracks:           tracks,
+		})
+	}
+	return activity, true
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) tcxPoint(t NullableTime, distance float64) tcxPoint {
+	point := tcxPoint{
+		Time:     t,
+		Position: &tcxPosition{Lat: wp.Lat, Lon: wp.Lon},
+		Altitude: wp.Ele,
+		Distance: NewNullableFloat64(distance),
+	}
+	if tpx, _ := wp.TrackPointExtension(); tpx != nil {
+		if tpx.HR.Valid {
+			point.HeartRate = &tcxHeartRate{Value: tpx.HR.Int}
+		}
+		point.Cadence = tpx.Cad
+	}
+	if ax, _ := wp.ActivityExtension(); ax != nil {
+		point.Extensions = &tcxPointExtensions{TPX: ax}
+	}
+	return point
+}
+
+func (wp *Wpt) tcxCoursePoint() tcxCoursePoint {
+	return tcxCoursePoint{
+		Name:      tcxToken(wp.Name, tcxMaxCoursePointName),
+		Time:      wp.Time,
+		Position:  tcxPosition{Lat: wp.Lat, Lon: wp.Lon},
+		Altitude:  wp.Ele,
+		PointType: tcxPointType(wp.Type),
+		Notes:     wp.Cmt,
+	}
+}
+
+/*==========================================================*/
+// tcxActivity
+func (activity tcxActivity) toTrk() Trk {
+	trk := Trk{Name: activity.ID, Type: activity.Sport, Desc: activity.Notes}
+	for _, lap := range activity.Laps {
+		var seg Trkseg
+		for _, track := range lap.Tracks {
+			seg.Waypoints = append(seg.Waypoints, track.toWaypoints()...)
+		}
+		trk.Segments = append(trk.Segments, seg)
+	}
+	return trk
+}
+
+/*==========================================================*/
+// tcxCourse
+func (course tcxCourse) toRte() Rte {
+	rte := Rte{Name: course.Name, Desc: course.Notes}
+	for _, track := range course.Tracks {
+		rte.Waypoints = append(rte.Waypoints, track.toWaypoints()...)
+	}
+	return rte
+}
+
+/*==========================================================*/
+// tcxCoursePoint
+func (point tcxCoursePoint) toWpt() Wpt {
+	return Wpt{
+		Lat:  point.Position.Lat,
+		Lon:  point.Position.Lon,
+		Ele:  point.Altitude,
+		Time: point.Time,
+		Name: point.Name,
+		Cmt:  point.Notes,
+		Type: point.PointType,
+	}
+}
+
+/*==========================================================*/
+// tcxTrack
+func (track tcxTrack) toWaypoints() Waypoints {
+	var points Waypoints
+	for _, point := range track.Points {
+		if point.Position == nil {
+			continue
+		}
+		wp := Wpt{Lat: point.Position.Lat, Lon: point.Position.Lon, Ele: point.Altitude, Time: point.Time}
+		if point.HeartRate != nil || point.Cadence.Valid {
+			tpx := &TrackPointExtension{Cad: point.Cadence}
+			if point.HeartRate != nil {
+				tpx.HR.SetValue(point.HeartRate.Value)
+			}
+			wp.SetTrackPointExtension(tpx)
+		}
+		if point.Extensions != nil && point.Extensions.TPX != nil {
+			wp.SetActivityExtension(point.Extensions.TPX)
+		}
+		wp.SetDistanceMeters(point.Distance)
+		points = append(points, wp)
+	}
+	return points
+}
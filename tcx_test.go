@@ -0,0 +1,196 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseTCXSample(t *testing.T) *Gpx {
+	f, err := os.Open("testdata/activity_sample.tcx")
+	assert.Equal(t, nil, err)
+	defer f.Close()
+	gpx, err := ParseTCXWithReader(f)
+	assert.Equal(t, nil, err)
+	return gpx
+}
+
+func TestParseTCX(t *testing.T) {
+	gpx := parseTCXSample(t)
+
+	assert.Equal(t, 1, len(gpx.Tracks))
+	trk := gpx.Tracks[0]
+	assert.Equal(t, "2016-06-29T10:00:00.000Z", trk.Name)
+	assert.Equal(t, TCX_BIKING, trk.Type)
+	assert.Equal(t, "Morning ride", trk.Desc)
+	assert.Equal(t, 2, len(trk.Segments))
+	// The point without position is skipped.
+	assert.Equal(t, 2, len(trk.Segments[0].Waypoints))
+	assert.Equal(t, 1, len(trk.Segments[1].Waypoints))
+
+	wp := trk.Segments[0].Waypoints[0]
+	assert.Equal(t, 46.0, wp.Lat)
+	assert.Equal(t, 13.5, wp.Lon)
+	assert.Equal(t, NewNullableFloat64(120.4), wp.Ele)
+	assert.Equal(t, "2016-06-29T10:00:00Z", wp.Time.String())
+	tpx, _ := wp.TrackPointExtension()
+	assert.Equal(t, &TrackPointExtension{HR: NewNullableInt(98), Cad: NewNullableInt(80)}, tpx)
+	ax, _ := wp.ActivityExtension()
+	assert.Equal(t, &ActivityExtension{Speed: NewNullableFloat64(4.2), Watts: NewNullableInt(180)}, ax)
+	assert.Equal(t, NewNullableFloat64(0), wp.DistanceMeters())
+	assert.Equal(t, NewNullableFloat64(42.5), trk.Segments[0].Waypoints[1].DistanceMeters())
+	ax, _ = trk.Segments[0].Waypoints[1].ActivityExtension()
+	assert.Equal(t, (*ActivityExtension)(nil), ax)
+
+	assert.Equal(t, 1, len(gpx.Routes))
+	assert.Equal(t, "Loop", gpx.Routes[0].Name)
+	assert.Equal(t, 2, len(gpx.Routes[0].Waypoints))
+	assert.Equal(t, 1, len(gpx.Waypoints))
+	assert.Equal(t, "Turn", gpx.Waypoints[0].Name)
+	assert.Equal(t, "Left", gpx.Waypoints[0].Type)
+
+	// The extensions are written to GPX.
+	content := string(gpx.ToXML())
+	assert.Equal(t, true, strings.Contains(content, `<ax:TPX xmlns:ax="http://www.garmin.com/xmlschemas/ActivityExtension/v2"><ax:Speed>4.2</ax:Speed><ax:Watts>180</ax:Watts></ax:TPX>`))
+	assert.Equal(t, true, strings.Contains(content, `<gpxgo:DistanceMeters xmlns:gpxgo="https://github.com/pikeszfish/gpxgo/v1">42.5</gpxgo:DistanceMeters>`))
+}
+
+func TestTCXRoundTrip(t *testing.T) {
+	gpx := parseTCXSample(t)
+	tcx, err := gpx.ToTCX()
+	assert.Equal(t, nil, err)
+	content := string(tcx)
+	for _, expected := range []string{
+		`<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">`,
+		`<Activity Sport="Biking">
+      <Id>2016-06-29T10:00:00Z</Id>
+      <Lap StartTime="2016-06-29T10:00:00Z">
+        <TotalTimeSeconds>10</TotalTimeSeconds>`,
+		`<HeartRateBpm>
+              <Value>98</Value>
+            </HeartRateBpm>
+            <Cadence>80</Cadence>
+            <Extensions>
+              <TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+                <Speed>4.2</Speed>
+                <Watts>180</Watts>
+              </TPX>
+            </Extensions>`,
+		`<Name>Loop</Name>
+      <Lap>
+        <TotalTimeSeconds>60</TotalTimeSeconds>`,
+		`<CoursePoint>
+        <Name>Turn</Name>
+        <Time>2016-06-29T09:00:30Z</Time>`,
+	} {
+		assert.Equal(t, true, strings.Contains(content, expected), expected)
+	}
+
+	reparsed, err := ParseTCXWithContent([]byte(content))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, TCX_BIKING, reparsed.Tracks[0].Type)
+	assert.Equal(t, gpx.Tracks[0].Segments[0], reparsed.Tracks[0].Segments[0])
+	// The distance is carried over from the previous lap.
+	assert.Equal(t, NewNullableFloat64(42.5), reparsed.Tracks[0].Segments[1].Waypoints[0].DistanceMeters())
+	assert.Equal(t, gpx.Routes[0].Name, reparsed.Routes[0].Name)
+	assert.Equal(t, len(gpx.Routes[0].Waypoints), len(reparsed.Routes[0].Waypoints))
+	assert.Equal(t, gpx.Waypoints, reparsed.Waypoints)
+}
+
+func TestToTCXDistances(t *testing.T) {
+	gpx := NewGpx()
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	gpx.Tracks = []Trk{{Type: "running", Segments: []Trkseg{{Waypoints: Waypoints{
+		{Lat: 0, Lon: 0, Time: NewNullableTime(start)},
+		{Lat: 0.0005, Lon: 0},
+		{Lat: 0.001, Lon: 0, Time: NewNullableTime(start.Add(time.Minute))},
+	}}}}}
+	tcx, err := gpx.ToTCX()
+	assert.Equal(t, nil, err)
+	reparsed, err := ParseTCXWithContent(tcx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, TCX_RUNNING, reparsed.Tracks[0].Type)
+	// The point without time is left out, but not its distance.
+	points := reparsed.Tracks[0].Segments[0].Waypoints
+	assert.Equal(t, 2, len(points))
+	assert.Equal(t, 0.0, points[0].DistanceMeters().Float64)
+	assert.Equal(t, true, points[1].DistanceMeters().Float64 > 110 && points[1].DistanceMeters().Float64 < 112)
+}
+
+func TestToTCXUntimedTracks(t *testing.T) {
+	gpx := NewGpx()
+	start := time.Date(2016, 6, 29, 10, 0, 0, 0, time.UTC)
+	gpx.Tracks = []Trk{
+		{Name: "Untimed", Segments: []Trkseg{{Waypoints: Waypoints{{Lat: 0, Lon: 0}}}}},
+		{Name: "Empty", Segments: []Trkseg{{}}},
+		{Name: "Partly timed", Segments: []Trkseg{
+			{Waypoints: Waypoints{{Lat: 0, Lon: 0}}},
+			{Waypoints: Waypoints{{Lat: 0, Lon: 0.001, Time: NewNullableTime(start)}}},
+		}},
+	}
+	tcx, err := gpx.ToTCX()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, strings.Contains(string(tcx), "0001-01-01"))
+
+	// Every activity has a lap.
+	reparsed, err := ParseTCXWithContent(tcx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(reparsed.Tracks))
+	assert.Equal(t, 1, len(reparsed.Tracks[0].Segments))
+	assert.Equal(t, FormatTime(start), reparsed.Tracks[0].Name)
+}
+
+func TestToTCXCoursePoints(t *testing.T) {
+	start := time.Date(2016, 6, 29, 9, 0, 0, 0, time.UTC)
+	gpx := NewGpx()
+	gpx.Routes = []Rte{
+		{Name: "North", Waypoints: Waypoints{{Lat: 46, Lon: 13, Time: NewNullableTime(start)}}},
+		{Name: "South", Waypoints: Waypoints{{Lat: 45, Lon: 13, Time: NewNullableTime(start)}}},
+	}
+	gpx.Waypoints = Waypoints{
+		{Lat: 45.1, Lon: 13, Time: NewNullableTime(start), Name: "Summit", Type: "summit"},
+		{Lat: 45.9, Lon: 13, Time: NewNullableTime(start), Name: "Spring"},
+		{Lat: 45.9, Lon: 13, Name: "Untimed"},
+	}
+	tcx, err := gpx.ToTCX()
+	assert.Equal(t, nil, err)
+	reparsed, err := ParseTCXWithContent(tcx)
+	assert.Equal(t, nil, err)
+
+	// In course order, each with the nearest course
+	assert.Equal(t, 3, len(reparsed.Waypoints))
+	assert.Equal(t, "Spring", reparsed.Waypoints[0].Name)
+	assert.Equal(t, "Generic", reparsed.Waypoints[0].Type)
+	// At the time of the nearest route point
+	assert.Equal(t, "Untimed", reparsed.Waypoints[1].Name)
+	assert.Equal(t, NewNullableTime(start), reparsed.Waypoints[1].Time)
+	assert.Equal(t, "Summit", reparsed.Waypoints[2].Name)
+	assert.Equal(t, "Summit", reparsed.Waypoints[2].Type)
+}
+
+func TestToTCXUntimedCourse(t *testing.T) {
+	gpx := NewGpx()
+	gpx.Routes = []Rte{{Name: "Around the lake twice", Waypoints: Waypoints{
+		{Lat: 0, Lon: 0},
+		{Lat: 0.001, Lon: 0},
+	}}}
+	gpx.Waypoints = Waypoints{{Lat: 0.001, Lon: 0, Name: "Lakeside café"}}
+	tcx, err := gpx.ToTCX()
+	assert.Equal(t, nil, err)
+	reparsed, err := ParseTCXWithContent(tcx)
+	assert.Equal(t, nil, err)
+
+	// The points are timed at 5 m/s.
+	points := reparsed.Routes[0].Waypoints
+	assert.Equal(t, 2, len(points))
+	assert.Equal(t, true, points[0].Time.Valid)
+	elapsed := points[1].Time.Time.Sub(points[0].Time.Time)
+	assert.Equal(t, true, elapsed > 22*time.Second && elapsed < 23*time.Second, elapsed)
+	assert.Equal(t, "Around the lake", reparsed.Routes[0].Name)
+
+	assert.Equal(t, 1, len(reparsed.Waypoints))
+	assert.Equal(t, "Lakeside c", reparsed.Waypoints[0].Name)
+	assert.Equal(t, points[1].Time, reparsed.Waypoints[0].Time)
+}
@@ -0,0 +1,98 @@
+package gpxgo
+
+import (
+	"encoding/xml"
+)
+
+const (
+	TPX_V1_NAMESPACE = "http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+	TPX_V2_NAMESPACE = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	TPX_PREFIX       = "gpxtpx"
+)
+
+// TrackPointExtension is the Garmin gpxtpx:TrackPointExtension of a track
+// point, v1 or v2. Speed, Course and Bearing only exist in v2.
+type TrackPointExtension struct {
+	ATemp      NullableFloat64 `xml:"atemp,omitempty"`   // air temperature, °C
+	WTemp      NullableFloat64 `xml:"wtemp,omitempty"`   // water temperature, °C
+	Depth      NullableFloat64 `xml:"depth,omitempty"`   // meters
+	HR         NullableInt     `xml:"hr,omitempty"`      // beats per minute
+	Cad        NullableInt     `xml:"cad,omitempty"`     // revolutions per minute
+	Speed      NullableFloat64 `xml:"speed,omitempty"`   // m/s
+	Course     NullableFloat64 `xml:"course,omitempty"`  // degrees
+	Bearing    NullableFloat64 `xml:"bearing,omitempty"` // degrees
+	Extensions *Extensions     `xml:"Extensions,omitempty"`
+}
+
+func init() {
+	RegisterExtension(TPX_V1_NAMESPACE, "TrackPointExtension", TPX_PREFIX, TrackPointExtension{})
+	RegisterExtension(TPX_V2_NAMESPACE, "TrackPointExtension", TPX_PREFIX, TrackPointExtension{})
+}
+
+/*==========================================================*/
+// TrackPointExtension
+
+// IsV2 reports whether tpx uses fields only defined in v2.
+func (tpx *TrackPointExtension) IsV2() bool {
+	return tpx.Speed.Valid || tpx.Course.Valid || tpx.Bearing.Valid
+}
+
+// interpolate interpolates the temperatures, depth, heart rate, cadence and
+// speed; course and bearing are those of the nearer point.
+func (tpx *TrackPointExtension) interpolate(v, v2 interface{}, fraction float64) {
+	tpx1, ok := v.(*TrackPointExtension)
+	tpx2, ok2 := v2.(*TrackPointExtension)
+	if !ok || !ok2 {
+		return
+	}
+	interpolateFloat(&tpx.ATemp, tpx1.ATemp, tpx2.ATemp, fraction)
+	interpolateFloat(&tpx.WTemp, tpx1.WTemp, tpx2.WTemp, fraction)
+	interpolateFloat(&tpx.Depth, tpx1.Depth, tpx2.Depth, fraction)
+	interpolateInt(&tpx.HR, tpx1.HR, tpx2.HR, fraction)
+	interpolateInt(&tpx.Cad, tpx1.Cad, tpx2.Cad, fraction)
+	interpolateFloat(&tpx.Speed, tpx1.Speed, tpx2.Speed, fraction)
+}
+
+/*==========================================================*/
+// Wpt
+
+// TrackPointExtension returns the Garmin TrackPointExtension of the point,
+// or nil if it has none. The error is that of a malformed element, which is
+// still written back as read.
+func (wp *Wpt) TrackPointExtension() (*TrackPointExtension, error) {
+	for _, namespace := range []string{TPX_V2_NAMESPACE, TPX_V1_NAMESPACE} {
+		v, err := wp.Extensions.Lookup(namespace, "TrackPointExtension")
+		if tpx, ok := v.(*TrackPointExtension); ok || err != nil {
+			return tpx, err
+		}
+	}
+	return nil, nil
+}
+
+// SetTrackPointExtension replaces the TrackPointExtension of the point, or
+// removes it if tpx is nil. Other extensions are left untouched. The element
+// is written in the v2 namespace if it uses v2 fields, in the namespace it was
+// read with otherwise.
+func (wp *Wpt) SetTrackPointExtension(tpx *TrackPointExtension) {
+	namespace := TPX_V1_NAMESPACE
+	if v, err := wp.Extensions.Lookup(TPX_V2_NAMESPACE, "TrackPointExtension"); v != nil || err != nil {
+		namespace = TPX_V2_NAMESPACE
+	}
+	if tpx != nil && tpx.IsV2() && namespace == TPX_V1_NAMESPACE && wp.Extensions != nil {
+		// Upgrade in place, keeping the position among the other extensions
+		for i, item := range wp.Extensions.Items {
+			if item.Name == (xml.Name{Space: TPX_V1_NAMESPACE, Local: "TrackPointExtension"}) {
+				wp.Extensions.Items[i].Name.Space = TPX_V2_NAMESPACE
+			}
+		}
+	}
+	if tpx != nil && tpx.IsV2() {
+		namespace = TPX_V2_NAMESPACE
+	}
+
+	var v interface{}
+	if tpx != nil {
+		v = tpx
+	}
+	wp.Extensions = wp.Extensions.Set(namespace, "TrackPointExtension", v)
+}
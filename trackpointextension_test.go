@@ -0,0 +1,120 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"strings"
+	"testing"
+)
+
+var garminTrackPoints = []byte(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxx="http://www.garmin.com/xmlschemas/GpxExtensions/v3" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1" creator="Oregon 400t" version="1.1">
+  <trk>
+    <trkseg>
+      <trkpt lat="47.644548" lon="-122.326897">
+        <ele>4.46</ele>
+        <time>2009-10-17T18:37:26Z</time>
+        <extensions>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:atemp>11.5</gpxtpx:atemp>
+            <gpxtpx:hr>143</gpxtpx:hr>
+            <gpxtpx:cad>88</gpxtpx:cad>
+            <gpxtpx:Extensions><vendor:power xmlns:vendor="urn:vendor">250</vendor:power></gpxtpx:Extensions>
+          </gpxtpx:TrackPointExtension>
+          <gpxx:Other>kept</gpxx:Other>
+        </extensions>
+      </trkpt>
+      <trkpt lat="47.644548" lon="-122.326897">
+        <ele>4.94</ele>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+func TestTrackPointExtension(t *testing.T) {
+	gpx, err := ParseWithContent(garminTrackPoints)
+	assert.Equal(t, nil, err)
+	wps := gpx.Tracks[0].Segments[0].Waypoints
+
+	tpx, err := wps[0].TrackPointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewNullableFloat64(11.5), tpx.ATemp)
+	assert.Equal(t, NewNullableInt(143), tpx.HR)
+	assert.Equal(t, NewNullableInt(88), tpx.Cad)
+	assert.Equal(t, true, tpx.WTemp.IsNull())
+	assert.Equal(t, false, tpx.IsV2())
+	nested, _ := tpx.Extensions.XML()
+	assert.Equal(t, `<vendor:power xmlns:vendor="urn:vendor">250</vendor:power>`, nested)
+
+	tpx, err = wps[1].TrackPointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, (*TrackPointExtension)(nil), tpx)
+}
+
+func TestSetTrackPointExtension(t *testing.T) {
+	gpx, err := ParseWithContent(garminTrackPoints)
+	assert.Equal(t, nil, err)
+	wps := gpx.Tracks[0].Segments[0].Waypoints
+
+	// Editing keeps the nested extensions and the other elements.
+	tpx, _ := wps[0].TrackPointExtension()
+	tpx.HR.SetValue(150)
+	wps[0].SetTrackPointExtension(tpx)
+	info, _ := wps[0].Extensions.XML()
+	assert.Equal(t, `<gpxtpx:TrackPointExtension xmlns:gpxtpx="`+TPX_V1_NAMESPACE+`"><gpxtpx:atemp>11.5</gpxtpx:atemp><gpxtpx:hr>150</gpxtpx:hr><gpxtpx:cad>88</gpxtpx:cad>`+
+		`<gpxtpx:Extensions><vendor:power xmlns:vendor="urn:vendor">250</vendor:power></gpxtpx:Extensions></gpxtpx:TrackPointExtension>`+
+		`<gpxx:Other xmlns:gpxx="`+GPXX_NAMESPACE+`">kept</gpxx:Other>`, info)
+
+	// v2 fields switch the element to the v2 namespace.
+	tpx.Speed.SetValue(3.5)
+	wps[0].SetTrackPointExtension(tpx)
+	info, _ = wps[0].Extensions.XML()
+	assert.Equal(t, true, strings.HasPrefix(info, `<gpxtpx:TrackPointExtension xmlns:gpxtpx="`+TPX_V2_NAMESPACE+`">`))
+	assert.Equal(t, 2, len(wps[0].Extensions.Items))
+
+	// A new extension declares its namespace and survives a round trip.
+	hr := &TrackPointExtension{}
+	hr.HR.SetValue(99)
+	wps[1].SetTrackPointExtension(hr)
+	reparsed, err := ParseWithContent(gpx.ToXML())
+	assert.Equal(t, nil, err)
+	rwps := reparsed.Tracks[0].Segments[0].Waypoints
+	tpx2, err := rwps[1].TrackPointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewNullableInt(99), tpx2.HR)
+	assert.Equal(t, TPX_V1_NAMESPACE, rwps[1].Extensions.Items[0].Name.Space)
+	tpx2, _ = rwps[0].TrackPointExtension()
+	assert.Equal(t, NewNullableFloat64(3.5), tpx2.Speed)
+
+	// Removing the only extension removes <extensions>.
+	wps[1].SetTrackPointExtension(nil)
+	assert.Equal(t, (*Extensions)(nil), wps[1].Extensions)
+
+	// v2 fields on a point without extensions
+	bare := &Wpt{}
+	bare.SetTrackPointExtension(&TrackPointExtension{Speed: NewNullableFloat64(3)})
+	assert.Equal(t, 1, len(bare.Extensions.Items))
+	assert.Equal(t, TPX_V2_NAMESPACE, bare.Extensions.Items[0].Name.Space)
+	tpx2, err = bare.TrackPointExtension()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, NewNullableFloat64(3), tpx2.Speed)
+}
+
+func TestMalformedTrackPointExtension(t *testing.T) {
+	content := strings.Replace(string(garminTrackPoints), "<gpxtpx:hr>143</gpxtpx:hr>", "<gpxtpx:hr>120.5</gpxtpx:hr>", 1)
+	for _, lenient := range []bool{false, true} {
+		gpx, err := ParseWithOptions(strings.NewReader(content), ParseOptions{Lenient: lenient})
+		assert.Equal(t, nil, err)
+		wps := gpx.Tracks[0].Segments[0].Waypoints
+		assert.Equal(t, 2, len(wps))
+		assert.Equal(t, 0, len(gpx.Warnings))
+
+		tpx, err := wps[0].TrackPointExtension()
+		assert.Equal(t, (*TrackPointExtension)(nil), tpx)
+		assert.NotEqual(t, nil, err)
+		assert.Equal(t, true, strings.HasPrefix(err.Error(), "gpxgo: extension TrackPointExtension: "), err.Error())
+
+		// Written back as read
+		xml := string(gpx.ToXML())
+		assert.Equal(t, true, strings.Contains(xml, "<gpxtpx:hr>120.5</gpxtpx:hr>"), xml)
+	}
+}
@@ -0,0 +1,396 @@
+package gpxgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Violation is a breach of the GPX 1.1 schema. Path locates the element, e.g.
+// trk[0].trkseg[2].trkpt[17]; it is empty for <gpx> itself.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+type validator struct {
+	violations []Violation
+}
+
+// a GPX element being scanned by validateStructure
+type scanFrame struct {
+	path     string
+	sequence []string
+	position int
+	counts   map[string]int
+}
+
+var (
+	wptSequence = []string{"ele", "time", "magvar", "geoidheight", "name", "cmt", "desc", "src", "link*",
+		"sym", "type", "fix", "sat", "hdop", "vdop", "pdop", "ageofdgpsdata", "dgpsid", "extensions"}
+
+	// Children of the GPX 1.1 complex types in schema order, "*" marking those
+	// that may repeat
+	gpxSequences = map[string][]string{
+		"gpx":       {"metadata", "wpt*", "rte*", "trk*", "extensions"},
+		"metadata":  {"name", "desc", "author", "copyright", "link*", "time", "keywords", "bounds", "extensions"},
+		"wpt":       wptSequence,
+		"rtept":     wptSequence,
+		"trkpt":     wptSequence,
+		"rte":       {"name", "cmt", "desc", "src", "link*", "number", "type", "extensions", "rtept*"},
+		"trk":       {"name", "cmt", "desc", "src", "link*", "number", "type", "extensions", "trkseg*"},
+		"trkseg":    {"trkpt*", "extensions"},
+		"author":    {"name", "email", "link"},
+		"copyright": {"year", "license"},
+		"link":      {"text", "type"},
+		"email":     {},
+		"bounds":    {},
+	}
+
+	// Attributes that read as 0 when missing
+	requiredAttrs = map[string][]string{
+		"wpt":    {"lat", "lon"},
+		"rtept":  {"lat", "lon"},
+		"trkpt":  {"lat", "lon"},
+		"bounds": {"minlat", "minlon", "maxlat", "maxlon"},
+	}
+
+	fixValues   = []string{"none", "2d", "3d", "dgps", "pps"}
+	yearPattern = regexp.MustCompile(`^-?[0-9]{4,}(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+)
+
+/*==========================================================*/
+// Static
+
+// ValidateWithContent parses content and validates it like Gpx.Validate,
+// adding the checks only possible on the XML: element order, unexpected
+// elements and missing coordinates. The error is only set if content cannot
+// be parsed. Gzip and bzip2 compressed documents are decompressed.
+func ValidateWithContent(content []byte) ([]Violation, error) {
+	r, err := decompress(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if content, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	}
+	g, err := ParseWithContent(content)
+	if err != nil {
+		return nil, err
+	}
+	violations, err := validateStructure(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, g.Validate()...), nil
+}
+
+func ValidateWithReader(r io.Reader) ([]Violation, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateWithContent(content)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(path, name string, i int) string {
+	return joinPath(path, fmt.Sprintf("%s[%d]", name, i))
+}
+
+// validateStructure checks the element order of a GPX 1.1 document. Extension
+// content is not checked, nor the content of a root element outside of the
+// GPX 1.1 namespace, e.g. of GPX 1.0, which is a violation itself.
+func validateStructure(r io.Reader) ([]Violation, error) {
+	v := new(validator)
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	var stack []*scanFrame
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return v.violations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				if t.Name.Space != GPX11_NAMESPACE {
+					v.add("", "namespace %q is not %s", t.Name.Space, GPX11_NAMESPACE)
+					return v.violations, nil
+				}
+				stack = append(stack, &scanFrame{sequence: gpxSequences["gpx"], counts: map[string]int{}})
+				continue
+			}
+			parent := stack[len(stack)-1]
+			if parent == nil {
+				stack = append(stack, nil)
+				continue
+			}
+			stack = append(stack, v.scanElement(parent, t))
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// scanElement checks start against the sequence of parent and returns its
+// own frame, or nil if its content is not checked.
+func (v *validator) scanElement(parent *scanFrame, start xml.StartElement) *scanFrame {
+	name := start.Name.Local
+	index := -1
+	repeats := false
+	if start.Name.Space == GPX11_NAMESPACE {
+		for i, child := range parent.sequence {
+			if strings.TrimSuffix(child, "*") == name {
+				index, repeats = i, strings.HasSuffix(child, "*")
+			}
+		}
+	}
+	if index < 0 {
+		v.add(parent.path, "unexpected element <%s>", name)
+		return nil
+	}
+
+	count := parent.counts[name]
+	parent.counts[name]++
+	path := joinPath(parent.path, name)
+	if repeats {
+		path = indexPath(parent.path, name, count)
+	}
+	switch {
+	case index < parent.position:
+		v.add(path, "<%s> must come before <%s>", name, strings.TrimSuffix(parent.sequence[parent.position], "*"))
+	case count > 0 && !repeats:
+		v.add(path, "<%s> may only appear once", name)
+	default:
+		parent.position = index
+	}
+
+	for _, required := range requiredAttrs[name] {
+		found := false
+		for _, attr := range start.Attr {
+			found = found || attr.Name == xml.Name{Local: required}
+		}
+		if !found {
+			v.add(path, "missing attribute %s", required)
+		}
+	}
+
+	sequence, found := gpxSequences[name]
+	if !found || name == "extensions" {
+		return nil
+	}
+	return &scanFrame{path: path, sequence: sequence, counts: map[string]int{}}
+}
+
+func (v *validator) checkLatLon(path, what string, lat, lon float64) {
+	if lat < -90 || lat > 90 {
+		v.add(path, "%slat %v is not in [-90, 90]", what, lat)
+	}
+	if lon < -180 || lon >= 180 {
+		v.add(path, "%slon %v is not in [-180, 180)", what, lon)
+	}
+}
+
+func (v *validator) checkLink(path string, link *Link) {
+	if link.Href == "" {
+		v.add(path, "missing href")
+	} else if _, err := url.Parse(link.Href); err != nil {
+		v.add(path, "href %q is not a URI", link.Href)
+	}
+}
+
+func (v *validator) checkLinks(path string, links []Link) {
+	for i := range links {
+		v.checkLink(indexPath(path, "link", i), &links[i])
+	}
+}
+
+func (v *validator) checkEmail(path string, email *Email) {
+	if !validEmailPart(email.Id) {
+		v.add(path, "invalid email id %q", email.Id)
+	}
+	if !validEmailPart(email.Domain) || strings.HasPrefix(email.Domain, ".") ||
+		strings.HasSuffix(email.Domain, ".") || strings.Contains(email.Domain, "..") {
+		v.add(path, "invalid email domain %q", email.Domain)
+	}
+}
+
+func validEmailPart(part string) bool {
+	return part != "" && !strings.ContainsAny(part, "@ \t\r\n")
+}
+
+func (v *validator) checkExtensions(path string, ext *Extensions) {
+	if ext == nil {
+		return
+	}
+	for _, item := range ext.Items {
+		if item.Name.Space == "" || item.Name.Space == GPX11_NAMESPACE {
+			v.add(joinPath(path, "extensions"), "<%s> must be in a namespace other than GPX", item.Name.Local)
+		}
+	}
+}
+
+func (v *validator) checkUnknown(path string, unknown []Extension) {
+	for _, item := range unknown {
+		v.add(path, "unexpected element <%s>", item.Name.Local)
+	}
+}
+
+/*==========================================================*/
+// Violation
+func (vi Violation) String() string {
+	if vi.Path == "" {
+		return vi.Message
+	}
+	return vi.Path + ": " + vi.Message
+}
+
+/*==========================================================*/
+// Gpx
+
+// Validate checks the values of the document against the GPX 1.1 schema and
+// returns the violations found, nil if there are none. A document with
+// Version "1.0" is a violation, to be converted first (see ToXMLVersion).
+// The element order of a parsed document is checked by ValidateWithContent.
+func (g *Gpx) Validate() []Violation {
+	v := new(validator)
+	if g.Version != "1.1" {
+		v.add("", "version %q is not 1.1", g.Version)
+	}
+	if g.Creator == "" {
+		v.add("", "missing creator")
+	}
+	if g.Metadata != nil {
+		g.Metadata.validate(v, "metadata")
+	}
+	for i := range g.Waypoints {
+		g.Waypoints[i].validate(v, indexPath("", "wpt", i))
+	}
+	for i := range g.Routes {
+		g.Routes[i].validate(v, indexPath("", "rte", i))
+	}
+	for i := range g.Tracks {
+		g.Tracks[i].validate(v, indexPath("", "trk", i))
+	}
+	v.checkExtensions("", g.Extensions)
+	v.checkUnknown("", g.Unknown)
+	return v.violations
+}
+
+/*==========================================================*/
+// Metadata
+func (md *Metadata) validate(v *validator, path string) {
+	if md.Author != nil {
+		author := joinPath(path, "author")
+		if md.Author.Email != nil {
+			v.checkEmail(joinPath(author, "email"), md.Author.Email)
+		}
+		if md.Author.Link != nil {
+			v.checkLink(joinPath(author, "link"), md.Author.Link)
+		}
+	}
+	if c := md.Copyright; c != nil {
+		copyright := joinPath(path, "copyright")
+		if c.Author == "" {
+			v.add(copyright, "missing author")
+		}
+		if c.Year != "" && !yearPattern.MatchString(c.Year) {
+			v.add(copyright, "year %q is not a year", c.Year)
+		}
+	}
+	v.checkLinks(path, md.Link)
+	if b := md.Bounds; b != nil {
+		bounds := joinPath(path, "bounds")
+		v.checkLatLon(bounds, "min", b.MinLat, b.MinLon)
+		v.checkLatLon(bounds, "max", b.MaxLat, b.MaxLon)
+	}
+	v.checkExtensions(path, md.Extensions)
+	v.checkUnknown(path, md.Unknown)
+}
+
+/*==========================================================*/
+// Routes
+func (r *Rte) validate(v *validator, path string) {
+	v.checkLinks(path, r.Link)
+	v.checkExtensions(path, r.Extensions)
+	for i := range r.Waypoints {
+		r.Waypoints[i].validate(v, indexPath(path, "rtept", i))
+	}
+	v.checkUnknown(path, r.Unknown)
+}
+
+/*==========================================================*/
+// Tracks
+func (t *Trk) validate(v *validator, path string) {
+	v.checkLinks(path, t.Link)
+	v.checkExtensions(path, t.Extensions)
+	for i := range t.Segments {
+		t.Segments[i].validate(v, indexPath(path, "trkseg", i))
+	}
+	v.checkUnknown(path, t.Unknown)
+}
+
+func (seg *Trkseg) validate(v *validator, path string) {
+	for i := range seg.Waypoints {
+		seg.Waypoints[i].validate(v, indexPath(path, "trkpt", i))
+	}
+	v.checkExtensions(path, seg.Extensions)
+	v.checkUnknown(path, seg.Unknown)
+}
+
+/*==========================================================*/
+// Wpt
+func (wp *Wpt) validate(v *validator, path string) {
+	v.checkLatLon(path, "", wp.Lat, wp.Lon)
+	if wp.Magvar != "" {
+		magvar, err := strconv.ParseFloat(strings.TrimSpace(wp.Magvar), 64)
+		if err != nil || magvar < 0 || magvar >= 360 {
+			v.add(path, "magvar %q is not in [0, 360)", wp.Magvar)
+		}
+	}
+	if wp.Geoidheight != "" {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(wp.Geoidheight), 64); err != nil {
+			v.add(path, "geoidheight %q is not a number", wp.Geoidheight)
+		}
+	}
+	v.checkLinks(path, wp.Link)
+	if wp.Fix != "" {
+		valid := false
+		for _, fix := range fixValues {
+			valid = valid || wp.Fix == fix
+		}
+		if !valid {
+			v.add(path, "fix %q is not one of %s", wp.Fix, strings.Join(fixValues, ", "))
+		}
+	}
+	if wp.Sat.Valid && wp.Sat.Int < 0 {
+		v.add(path, "sat %d is negative", wp.Sat.Int)
+	}
+	if wp.Dgpsid < 0 || wp.Dgpsid > 1023 {
+		v.add(path, "dgpsid %d is not in [0, 1023]", wp.Dgpsid)
+	}
+	v.checkExtensions(path, wp.Extensions)
+	v.checkUnknown(path, wp.Unknown)
+}
@@ -0,0 +1,85 @@
+package gpxgo
+
+import (
+	"github.com/bmizerany/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	gpx := NewGpx()
+	gpx.Metadata = &Metadata{
+		Author:    &Person{Email: &Email{Id: "john", Domain: "example..com"}},
+		Copyright: &Copyright{Year: "16"},
+		Bounds:    &Bounds{MinLat: -91, MinLon: -180, MaxLat: 10, MaxLon: 180},
+	}
+	gpx.Waypoints = Waypoints{{Lat: 45, Lon: 7, Fix: "4d", Magvar: "360", Dgpsid: 1024, Link: []Link{{Text: "no href"}}}}
+	seg := Trkseg{Waypoints: Waypoints{{Lat: 1, Lon: 1}, {Lat: 1, Lon: 1}, {Lat: 90.5, Lon: -180}}}
+	gpx.Tracks = []Trk{{Segments: []Trkseg{{}, {}, seg}}}
+	gpx.Tracks[0].Extensions = new(Extensions)
+	gpx.Tracks[0].Extensions.AddXML(`<speed>1</speed>`)
+
+	assert.Equal(t, []Violation{
+		{"metadata.author.email", `invalid email domain "example..com"`},
+		{"metadata.copyright", "missing author"},
+		{"metadata.copyright", `year "16" is not a year`},
+		{"metadata.bounds", "minlat -91 is not in [-90, 90]"},
+		{"metadata.bounds", "maxlon 180 is not in [-180, 180)"},
+		{"wpt[0]", `magvar "360" is not in [0, 360)`},
+		{"wpt[0].link[0]", "missing href"},
+		{"wpt[0]", `fix "4d" is not one of none, 2d, 3d, dgps, pps`},
+		{"wpt[0]", "dgpsid 1024 is not in [0, 1023]"},
+		{"trk[0].extensions", "<speed> must be in a namespace other than GPX"},
+		{"trk[0].trkseg[2].trkpt[2]", "lat 90.5 is not in [-90, 90]"},
+	}, gpx.Validate())
+	assert.Equal(t, "wpt[0]: dgpsid 1024 is not in [0, 1023]", gpx.Validate()[8].String())
+}
+
+func TestValidateValidDocuments(t *testing.T) {
+	for _, path := range []string{"testdata/St_Louis_Zoo_sample.gpx", "testdata/gpxx_sample.gpx"} {
+		gpx, err := ParseWithPath(path)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 0, len(gpx.Validate()), path)
+	}
+}
+
+func TestValidateGpx10(t *testing.T) {
+	content, err := ioutil.ReadFile("testdata/gpx10_sample.gpx")
+	assert.Equal(t, nil, err)
+	violations, err := ValidateWithContent(content)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Violation{
+		{"", `namespace "http://www.topografix.com/GPX/1/0" is not http://www.topografix.com/GPX/1/1`},
+		{"", `version "1.0" is not 1.1`},
+	}, violations)
+
+	// Once converted
+	gpx, err := ParseWithContent(content)
+	assert.Equal(t, nil, err)
+	converted, err := gpx.ToXMLVersion("1.1")
+	assert.Equal(t, nil, err)
+	violations, err = ValidateWithContent(converted)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(violations))
+}
+
+func TestValidateWithContent(t *testing.T) {
+	violations, err := ValidateWithContent([]byte(`<?xml version="1.0"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="test">
+  <wpt lat="1"><name>A</name><ele>3</ele><name>B</name></wpt>
+  <metadata><bounds minlat="1" minlon="1" maxlat="2"/></metadata>
+  <trk><trkseg><trkpt lat="1" lon="2"><speed>3</speed></trkpt></trkseg></trk>
+</gpx>`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Violation{
+		{"wpt[0]", "missing attribute lon"},
+		{"wpt[0].ele", "<ele> must come before <name>"},
+		{"wpt[0].name", "<name> may only appear once"},
+		{"metadata", "<metadata> must come before <wpt>"},
+		{"metadata.bounds", "missing attribute maxlon"},
+		{"trk[0].trkseg[0].trkpt[0]", "unexpected element <speed>"},
+	}, violations)
+
+	_, err = ValidateWithContent([]byte(`<gpx><wpt lat="x"/></gpx>`))
+	assert.NotEqual(t, nil, err)
+}